@@ -0,0 +1,141 @@
+package errors
+
+import (
+	"context"
+	"net/http"
+)
+
+// Code classifies an error by kind, independently of its message.
+type Code int
+
+// Codes of the errors, modeled after the gRPC status codes.
+const (
+	// Unknown is the code of errors without classification.
+	Unknown Code = iota
+	// InvalidArgument indicates a malformed or invalid request.
+	InvalidArgument
+	// NotFound indicates a missing resource.
+	NotFound
+	// AlreadyExists indicates a resource that cannot be created twice.
+	AlreadyExists
+	// PermissionDenied indicates an authenticated caller lacking permission.
+	PermissionDenied
+	// Unauthenticated indicates a caller without valid credentials.
+	Unauthenticated
+	// FailedPrecondition indicates a system state that does not allow the operation.
+	FailedPrecondition
+	// Conflict indicates a concurrent modification.
+	Conflict
+	// ResourceExhausted indicates an exceeded quota or rate limit.
+	ResourceExhausted
+	// Canceled indicates an operation canceled by the caller.
+	Canceled
+	// DeadlineExceeded indicates an operation that did not complete in time.
+	DeadlineExceeded
+	// Unimplemented indicates an unsupported operation.
+	Unimplemented
+	// Unavailable indicates a transient failure worth retrying.
+	Unavailable
+	// Internal indicates a bug or a broken invariant.
+	Internal
+)
+
+var (
+	// codeNames are the snake_case names of the codes.
+	codeNames = map[Code]string{
+		Unknown:            "unknown",
+		InvalidArgument:    "invalid_argument",
+		NotFound:           "not_found",
+		AlreadyExists:      "already_exists",
+		PermissionDenied:   "permission_denied",
+		Unauthenticated:    "unauthenticated",
+		FailedPrecondition: "failed_precondition",
+		Conflict:           "conflict",
+		ResourceExhausted:  "resource_exhausted",
+		Canceled:           "canceled",
+		DeadlineExceeded:   "deadline_exceeded",
+		Unimplemented:      "unimplemented",
+		Unavailable:        "unavailable",
+		Internal:           "internal",
+	}
+
+	// codeStatuses are the HTTP status codes of the codes.
+	codeStatuses = map[Code]int{
+		Unknown:            http.StatusInternalServerError,
+		InvalidArgument:    http.StatusBadRequest,
+		NotFound:           http.StatusNotFound,
+		AlreadyExists:      http.StatusConflict,
+		PermissionDenied:   http.StatusForbidden,
+		Unauthenticated:    http.StatusUnauthorized,
+		FailedPrecondition: http.StatusBadRequest, // 412 is reserved for conditional request headers
+		Conflict:           http.StatusConflict,
+		ResourceExhausted:  http.StatusTooManyRequests,
+		Canceled:           499, // Client Closed Request
+		DeadlineExceeded:   http.StatusGatewayTimeout,
+		Unimplemented:      http.StatusNotImplemented,
+		Unavailable:        http.StatusServiceUnavailable,
+		Internal:           http.StatusInternalServerError,
+	}
+)
+
+// String returns the snake_case name of the code.
+func (code Code) String() string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return codeNames[Unknown]
+}
+
+// HTTPStatus returns the HTTP status code of the code.
+func (code Code) HTTPStatus() int {
+	if status, ok := codeStatuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// NewCode returns an error with the code, the message and the stack trace of the caller.
+func NewCode(code Code, msg string) error {
+	return &Error{msg: msg, code: code, stack: capture(1)}
+}
+
+// WithCode returns an error wrapping err with the code. The stack trace of the caller is
+// captured only if err does not carry one yet. It returns nil if err is nil.
+func WithCode(err error, code Code) error {
+	if err == nil {
+		return nil
+	}
+	withCode := &Error{cause: err, code: code}
+	if StackTrace(err) == nil {
+		withCode.stack = capture(1)
+	}
+	return withCode
+}
+
+// CodeOf returns the outermost code of the error chain. Errors without code that wrap
+// context.Canceled or context.DeadlineExceeded get the matching code; others are Unknown.
+func CodeOf(err error) Code {
+	if layer := codeLayer(err); layer != nil {
+		return layer.code
+	}
+	switch {
+	case Is(err, context.Canceled):
+		return Canceled
+	case Is(err, context.DeadlineExceeded):
+		return DeadlineExceeded
+	}
+	return Unknown
+}
+
+// codeLayer returns the outermost layer of the error chain setting a code, or nil.
+func codeLayer(err error) *Error {
+	var coded *Error
+	walk(err, func(err error) bool {
+		if layer, ok := err.(*Error); ok && layer.code != Unknown {
+			coded = layer
+			return false
+		}
+		return true
+	})
+	return coded
+}
@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	notFound := NewCode(NotFound, "user not found")
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, Unknown},
+		{"plain", io.EOF, Unknown},
+		{"coded", notFound, NotFound},
+		{"wrapped", Wrap(notFound, "loading user"), NotFound},
+		{"stdlib wrapped", fmt.Errorf("handler: %w", notFound), NotFound},
+		{"outermost wins", WithCode(notFound, Internal), Internal},
+		{"joined", Join(io.EOF, notFound), NotFound},
+		{"canceled", Wrap(context.Canceled, "query"), Canceled},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), DeadlineExceeded},
+		{"coded context", WithCode(context.DeadlineExceeded, Unavailable), Unavailable},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CodeOf(test.err); got != test.want {
+				t.Errorf("CodeOf() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWithCode(t *testing.T) {
+	if WithCode(nil, NotFound) != nil {
+		t.Error("WithCode(nil) is not nil")
+	}
+	err := WithCode(io.EOF, Unavailable)
+	if err.Error() != "EOF" || !Is(err, io.EOF) {
+		t.Errorf("WithCode returned %q that does not wrap io.EOF", err)
+	}
+	if StackTrace(err) == nil || StackTrace(err).Frames()[0].Function != "errors.TestWithCode" {
+		t.Error("WithCode did not capture the stack trace of the caller for an error without one")
+	}
+
+	inner := New("inner")
+	if StackTrace(WithCode(inner, Internal)) != StackTrace(inner) {
+		t.Error("WithCode captured a second stack trace for an error carrying one")
+	}
+}
+
+func TestCodeHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code   Code
+		name   string
+		status int
+	}{
+		{Unknown, "unknown", http.StatusInternalServerError},
+		{InvalidArgument, "invalid_argument", http.StatusBadRequest},
+		{NotFound, "not_found", http.StatusNotFound},
+		{Unauthenticated, "unauthenticated", http.StatusUnauthorized},
+		{FailedPrecondition, "failed_precondition", http.StatusBadRequest},
+		{ResourceExhausted, "resource_exhausted", http.StatusTooManyRequests},
+		{Unavailable, "unavailable", http.StatusServiceUnavailable},
+		{Code(1000), "unknown", http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		if got := test.code.String(); got != test.name {
+			t.Errorf("Code(%d).String() = %q, want %q", int(test.code), got, test.name)
+		}
+		if got := test.code.HTTPStatus(); got != test.status {
+			t.Errorf("%s.HTTPStatus() = %d, want %d", test.code, got, test.status)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package errors provides errors that carry the stack trace captured where they were
+// created and structured key-value attributes merged along the wrap chain. It can be used
+// in place of the standard errors package, whose functions it re-exports.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+// Error is an error layer carrying a stack trace and attributes.
+type Error struct {
+	msg   string                 // Message of the layer, empty if it only adds attributes.
+	cause error                  // Wrapped error, if any.
+	attrs []slog.Attr            // Attributes of the layer.
+	code  Code                   // Classification code of the layer, Unknown if unset.
+	stack *stacktrace.StackTrace // Stack trace captured where the layer was created, if any.
+}
+
+// New returns an error with the message and the stack trace of the caller.
+func New(msg string) error {
+	return &Error{msg: msg, stack: capture(1)}
+}
+
+// Errorf returns an error formatted like fmt.Errorf, wrapping the %w operands, with the
+// stack trace of the caller.
+func Errorf(format string, args ...any) error {
+	return &Error{cause: fmt.Errorf(format, args...), stack: capture(1)}
+}
+
+// Wrap returns an error wrapping err with the message and the stack trace of the caller.
+// It returns nil if err is nil.
+func Wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{msg: msg, cause: err, stack: capture(1)}
+}
+
+// With returns an error wrapping err with attributes given as slog key-value pairs or
+// slog.Attr values, such as With(err, "user_id", id). The stack trace of the caller is
+// captured only if err does not carry one yet. It returns nil if err is nil.
+func With(err error, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	withAttrs := &Error{cause: err, attrs: argsToAttrs(args)}
+	if StackTrace(err) == nil {
+		withAttrs.stack = capture(1)
+	}
+	return withAttrs
+}
+
+// Error returns the message of the layer followed by the message of the wrapped error.
+func (err *Error) Error() string {
+	switch {
+	case err.cause == nil:
+		return err.msg
+	case err.msg == "":
+		return err.cause.Error()
+	default:
+		return err.msg + ": " + err.cause.Error()
+	}
+}
+
+// Unwrap returns the wrapped error.
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// StackTrace returns the stack trace captured where the layer was created, or nil.
+func (err *Error) StackTrace() *stacktrace.StackTrace {
+	return err.stack
+}
+
+// Format formats the error. The %+v verb renders the whole chain with stacks as
+// stacktrace.FormatError does; other verbs render the message.
+func (err *Error) Format(state fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && state.Flag('+'):
+		fmt.Fprint(state, stacktrace.FormatError(err))
+	case verb == 'q':
+		fmt.Fprintf(state, "%q", err.Error())
+	default:
+		fmt.Fprint(state, err.Error())
+	}
+}
+
+// Attrs returns the attributes of every layer of the error chain, including the branches
+// of joined errors. When layers set the same key, the outermost value wins.
+func Attrs(err error) []slog.Attr {
+	var attrs []slog.Attr
+	seen := make(map[string]bool)
+	walk(err, func(err error) bool {
+		if layer, ok := err.(*Error); ok {
+			for _, attr := range layer.attrs {
+				if !seen[attr.Key] {
+					seen[attr.Key] = true
+					attrs = append(attrs, attr)
+				}
+			}
+		}
+		return true
+	})
+	return attrs
+}
+
+// StackTrace returns the innermost stack trace of the error chain, which is the closest
+// to where the error originated, or nil if no layer carries one.
+func StackTrace(err error) *stacktrace.StackTrace {
+	var stackTrace *stacktrace.StackTrace
+	walk(err, func(err error) bool {
+		if stackTracer, ok := err.(stacktrace.StackTracer); ok && stackTracer.StackTrace() != nil {
+			stackTrace = stackTracer.StackTrace()
+		}
+		return true
+	})
+	return stackTrace
+}
+
+// walk calls fn for every error of the chain, outermost first and depth first through
+// joined errors, until fn returns false.
+func walk(err error, fn func(error) bool) bool {
+	for err != nil {
+		if !fn(err) {
+			return false
+		}
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, cause := range wrapper.Unwrap() {
+				if !walk(cause, fn) {
+					return false
+				}
+			}
+			return true
+		case interface{ Unwrap() error }:
+			err = wrapper.Unwrap()
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+// capture returns the stack trace of the caller of the function calling capture, skipping skip frames.
+func capture(skip int) *stacktrace.StackTrace {
+	return stacktrace.NewStackTrace(&stacktrace.Config{
+		BufferSize: stacktrace.DefaultConfig.BufferSize,
+		SkipFrames: skip + 1, // +1 to skip capture
+	})
+}
+
+// argsToAttrs converts slog-style arguments into attributes.
+func argsToAttrs(args []any) []slog.Attr {
+	record := slog.Record{}
+	record.Add(args...)
+	attrs := make([]slog.Attr, 0, record.NumAttrs())
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return attrs
+}
+
+// Is reports whether any error in err's tree matches target, as errors.Is does.
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As finds the first error in err's tree that matches target, as errors.As does.
+func As(err error, target any) bool {
+	return stderrors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err, as errors.Unwrap does.
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// Join returns an error that wraps the given errors, as errors.Join does.
+func Join(errs ...error) error {
+	return stderrors.Join(errs...)
+}
@@ -0,0 +1,109 @@
+package errors
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+func TestNew(t *testing.T) {
+	err := New("not found")
+	if err.Error() != "not found" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "not found")
+	}
+	stackTrace := StackTrace(err)
+	if stackTrace == nil || stackTrace.Frames()[0].Function != "errors.TestNew" {
+		t.Errorf("stack trace does not start at the caller of New: %v", stackTrace)
+	}
+}
+
+func TestErrorf(t *testing.T) {
+	err := Errorf("reading %s: %w", "config", io.EOF)
+	if err.Error() != "reading config: EOF" || !Is(err, io.EOF) {
+		t.Errorf("Errorf returned %q that does not wrap io.EOF", err)
+	}
+	if StackTrace(err).Frames()[0].Function != "errors.TestErrorf" {
+		t.Errorf("stack trace does not start at the caller of Errorf: %v", StackTrace(err).Frames())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	if Wrap(nil, "context") != nil {
+		t.Error("Wrap(nil) is not nil")
+	}
+	inner := New("inner")
+	err := Wrap(inner, "outer")
+	if err.Error() != "outer: inner" || Unwrap(err) != inner {
+		t.Errorf("Wrap returned %q that does not unwrap to the inner error", err)
+	}
+	if StackTrace(err) != inner.(*Error).StackTrace() {
+		t.Error("StackTrace does not return the innermost stack trace")
+	}
+}
+
+func TestWith(t *testing.T) {
+	if With(nil, "key", "value") != nil {
+		t.Error("With(nil) is not nil")
+	}
+
+	plain := With(io.EOF, "user_id", 42)
+	if plain.Error() != "EOF" || !Is(plain, io.EOF) {
+		t.Errorf("With returned %q that does not wrap io.EOF", plain)
+	}
+	if plain.(*Error).StackTrace() == nil {
+		t.Error("With did not capture a stack trace for an error without one")
+	}
+
+	inner := New("inner")
+	err := With(Wrap(With(inner, "user_id", 1, "tenant", "acme"), "outer"), "user_id", 2, slog.Bool("retry", true))
+	if err.(*Error).StackTrace() != nil {
+		t.Error("With captured a stack trace for an error carrying one")
+	}
+
+	attrs := Attrs(err)
+	expected := []string{"user_id=2", "retry=true", "tenant=acme"}
+	if len(attrs) != len(expected) {
+		t.Fatalf("Attrs = %v, want %v", attrs, expected)
+	}
+	for i, attr := range attrs {
+		if attr.String() != expected[i] {
+			t.Errorf("attribute %d = %s, want %s", i, attr, expected[i])
+		}
+	}
+}
+
+func TestAttrsJoin(t *testing.T) {
+	err := Join(With(io.EOF, "a", 1), nil, fmt.Errorf("wrapped: %w", With(io.ErrClosedPipe, "b", 2)))
+	attrs := Attrs(err)
+	if len(attrs) != 2 || attrs[0].Key != "a" || attrs[1].Key != "b" {
+		t.Errorf("Attrs = %v, want a and b", attrs)
+	}
+	if Attrs(nil) != nil || Attrs(io.EOF) != nil {
+		t.Error("Attrs of errors without attributes is not nil")
+	}
+	if StackTrace(io.EOF) != nil {
+		t.Error("StackTrace of an error without stack trace is not nil")
+	}
+}
+
+func TestErrorFormat(t *testing.T) {
+	err := Wrap(New("inner"), "outer")
+	if formatted := fmt.Sprintf("%v|%s|%q", err, err, err); formatted != `outer: inner|outer: inner|"outer: inner"` {
+		t.Errorf("formatted error = %q", formatted)
+	}
+	detailed := fmt.Sprintf("%+v", err)
+	if detailed != stacktrace.FormatError(err) || !strings.Contains(detailed, "\nCaused by: inner\n") {
+		t.Errorf("%%+v formatted error = %q", detailed)
+	}
+}
+
+func TestAs(t *testing.T) {
+	var target *Error
+	if !As(fmt.Errorf("wrapped: %w", New("inner")), &target) || target.Error() != "inner" {
+		t.Errorf("As did not find the *Error: %v", target)
+	}
+}
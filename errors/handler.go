@@ -0,0 +1,87 @@
+package errors
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	// StackKey is the key of the stack trace attribute added by Handler.
+	StackKey = "stack"
+)
+
+// Handler is a slog.Handler that adds the attributes of the error values of a record,
+// merged along their wrap chains, and the stack trace of the first error carrying one.
+// Errors attached with Logger.With are enriched likewise, and so are errors nested in
+// groups, whose attributes are added next to the group rather than inside it.
+type Handler struct {
+	next  slog.Handler
+	stack bool // Whether a stack trace was added by WithAttrs.
+}
+
+// NewHandler creates a handler passing the enriched records to next.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+// Enabled reports whether the next handler handles records at the level.
+func (handler *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return handler.next.Enabled(ctx, level)
+}
+
+// Handle adds the error attributes and stack trace to the record and passes it to the next handler.
+func (handler *Handler) Handle(ctx context.Context, record slog.Record) error {
+	var extra []slog.Attr
+	stack := handler.stack
+	record.Attrs(func(attr slog.Attr) bool {
+		extra = appendErrorAttrs(extra, attr, &stack)
+		return true
+	})
+	if len(extra) > 0 {
+		record = record.Clone()
+		record.AddAttrs(extra...)
+	}
+	return handler.next.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler whose next handler has the attributes, with the error
+// attributes and stack trace added as by Handle.
+func (handler *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var extra []slog.Attr
+	stack := handler.stack
+	for _, attr := range attrs {
+		extra = appendErrorAttrs(extra, attr, &stack)
+	}
+	if len(extra) > 0 {
+		attrs = append(attrs[:len(attrs):len(attrs)], extra...)
+	}
+	return &Handler{next: handler.next.WithAttrs(attrs), stack: stack}
+}
+
+// WithGroup returns a handler whose next handler has the group.
+func (handler *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: handler.next.WithGroup(name), stack: handler.stack}
+}
+
+// appendErrorAttrs appends the attributes of the errors of the attribute, including those
+// nested in groups, and the stack trace of the first error carrying one unless stack is set.
+func appendErrorAttrs(extra []slog.Attr, attr slog.Attr, stack *bool) []slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		for _, member := range value.Group() {
+			extra = appendErrorAttrs(extra, member, stack)
+		}
+	case slog.KindAny:
+		err, ok := value.Any().(error)
+		if !ok {
+			break
+		}
+		extra = append(extra, Attrs(err)...)
+		if stackTrace := StackTrace(err); stackTrace != nil && !*stack {
+			*stack = true
+			extra = append(extra, slog.String(StackKey, stackTrace.Frames().String()))
+		}
+	}
+	return extra
+}
@@ -0,0 +1,96 @@
+package errors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+
+	err := With(Wrap(New("connection refused"), "loading user"), "user_id", 42)
+	logger.Error("request failed", "err", err, "path", "/users/42")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding log record: %v", err)
+	}
+	if record["err"] != "loading user: connection refused" || record["path"] != "/users/42" {
+		t.Errorf("record lost its attributes: %v", record)
+	}
+	if record["user_id"] != float64(42) {
+		t.Errorf("record user_id = %v, want 42", record["user_id"])
+	}
+	stack, _ := record[StackKey].(string)
+	if !strings.Contains(stack, "errors.TestHandler") {
+		t.Errorf("record stack = %q, want the stack of the error", stack)
+	}
+}
+
+func TestHandlerWithoutErrors(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewTextHandler(&buf, nil))).With("service", "api").WithGroup("request")
+
+	logger.Info("plain", "err", io.EOF, "status", 200)
+	output := buf.String()
+	if strings.Contains(output, StackKey+"=") {
+		t.Errorf("record of an error without stack has a stack: %s", output)
+	}
+	if !strings.Contains(output, "service=api") || !strings.Contains(output, "request.status=200") {
+		t.Errorf("handler lost attributes or groups: %s", output)
+	}
+	if NewHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn})).Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Handler.Enabled does not follow the next handler")
+	}
+}
+
+func TestHandlerWithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	err := With(New("connection refused"), "user_id", 42)
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil))).With("err", err)
+
+	logger.Error("request failed", "err2", With(New("retry failed"), "attempt", 3))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding log record: %v", err)
+	}
+	if record["err"] != "connection refused" || record["user_id"] != float64(42) || record["attempt"] != float64(3) {
+		t.Errorf("record = %v, want the attributes of both errors", record)
+	}
+	if stack, _ := record[StackKey].(string); !strings.Contains(stack, "errors.TestHandlerWithAttrs") {
+		t.Errorf("record stack = %q, want the stack of the error attached with With", stack)
+	}
+	if strings.Count(buf.String(), `"`+StackKey+`"`) != 1 {
+		t.Errorf("record has more than one stack: %s", buf.String())
+	}
+}
+
+func TestHandlerGroup(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil)))
+
+	err := With(New("connection refused"), "user_id", 42)
+	logger.Error("request failed", slog.Group("req", "path", "/users/42", slog.Group("db", "err", err)))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decoding log record: %v", err)
+	}
+	if record["user_id"] != float64(42) {
+		t.Errorf("record user_id = %v, want 42 from the error in the group", record["user_id"])
+	}
+	if stack, _ := record[StackKey].(string); !strings.Contains(stack, "errors.TestHandlerGroup") {
+		t.Errorf("record stack = %q, want the stack of the error in the group", stack)
+	}
+	request, _ := record["req"].(map[string]any)
+	if database, _ := request["db"].(map[string]any); database["err"] != "connection refused" {
+		t.Errorf("record lost the group: %v", record)
+	}
+}
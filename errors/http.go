@@ -0,0 +1,91 @@
+package errors
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// ProblemContentType is the media type of RFC 9457 problem details.
+	ProblemContentType = "application/problem+json"
+)
+
+var (
+	// ProblemLogger logs the errors written by WriteProblem. If nil, the default logger is used.
+	// Loggers whose handler is not a Handler are wrapped so the stack trace is logged.
+	ProblemLogger *slog.Logger
+)
+
+// Problem is an RFC 9457 problem details object, extended with the error code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// NewProblem returns the problem details of the error for the request. Only the message
+// of the layer that set the code is exposed as detail, and only for client errors, so that
+// the wrapped causes and the server errors do not leak internals.
+func NewProblem(r *http.Request, err error) *Problem {
+	code := CodeOf(err)
+	status := code.HTTPStatus()
+	problem := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: r.URL.Path,
+		Code:     code.String(),
+	}
+	if problem.Title == "" {
+		problem.Title = code.String()
+	}
+	if layer := codeLayer(err); layer != nil && status < http.StatusInternalServerError {
+		problem.Detail = layer.msg
+	}
+	return problem
+}
+
+// WriteProblem writes the error as an RFC 9457 problem+json response with the status of
+// its code, and logs it with its attributes and stack trace server-side only. Server
+// errors are logged at error level, client errors at info level.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+
+	level := slog.LevelInfo
+	if problem.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	problemLogger().Log(r.Context(), level, "request failed", "err", err, "code", problem.Code,
+		"status", problem.Status, "method", r.Method, "path", r.URL.Path)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
+
+// problemLogger returns the logger of WriteProblem, wrapped with a Handler if needed.
+func problemLogger() *slog.Logger {
+	logger := ProblemLogger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if _, ok := logger.Handler().(*Handler); ok {
+		return logger
+	}
+	return slog.New(NewHandler(logger.Handler()))
+}
+
+// HandlerFunc is an HTTP handler returning an error, written with WriteProblem.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls the handler and writes its error, if any, as problem details.
+func (handler HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := handler(w, r); err != nil {
+		WriteProblem(w, r, err)
+	}
+}
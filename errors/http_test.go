@@ -0,0 +1,79 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteProblem(t *testing.T) {
+	var logs bytes.Buffer
+	ProblemLogger = slog.New(slog.NewJSONHandler(&logs, nil))
+	defer func() { ProblemLogger = nil }()
+
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		detail    string
+		wantLevel string
+	}{
+		{"client error", NewCode(NotFound, "user 42 not found"), http.StatusNotFound, "user 42 not found", "INFO"},
+		{"wrapped client error", Wrap(NewCode(InvalidArgument, "invalid user ID"), "parsing request"), http.StatusBadRequest, "invalid user ID", "INFO"},
+		{"client error with cause", WithCode(Wrap(New("sql: no rows in result set"), "querying users"), NotFound), http.StatusNotFound, "", "INFO"},
+		{"server error", Wrap(New("dial tcp: connection refused"), "loading user"), http.StatusInternalServerError, "", "ERROR"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs.Reset()
+			recorder := httptest.NewRecorder()
+			handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return test.err })
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d", recorder.Code, test.status)
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", contentType, ProblemContentType)
+			}
+			var problem Problem
+			if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decoding problem: %v", err)
+			}
+			if problem.Status != test.status || problem.Detail != test.detail || problem.Instance != "/users/42" ||
+				problem.Type != "about:blank" || problem.Title != http.StatusText(test.status) || problem.Code != CodeOf(test.err).String() {
+				t.Errorf("problem = %+v", problem)
+			}
+			if strings.Contains(recorder.Body.String(), "TestWriteProblem") {
+				t.Errorf("response leaks the stack trace: %s", recorder.Body)
+			}
+
+			var record map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("decoding log record: %v", err)
+			}
+			if record["level"] != test.wantLevel || record["path"] != "/users/42" || record["status"] != float64(test.status) {
+				t.Errorf("log record = %v", record)
+			}
+			if stack, _ := record[StackKey].(string); !strings.Contains(stack, "errors.TestWriteProblem") {
+				t.Errorf("log record stack = %q, want the stack of the error", stack)
+			}
+		})
+	}
+}
+
+func TestHandlerFuncWithoutError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handler := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusNoContent || recorder.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want 204 without body", recorder.Code, recorder.Body)
+	}
+}
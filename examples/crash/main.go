@@ -0,0 +1,8 @@
+package main
+
+import "github.com/turtak/go-kit/stacktrace/crash"
+
+func main() {
+	defer crash.Guard()
+	panic("something went wrong")
+}
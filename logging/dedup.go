@@ -0,0 +1,273 @@
+// Package logging provides slog handlers built on the stacktrace package.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+const (
+	// SuppressedKey is the key of the suppressed count attribute of summary records.
+	SuppressedKey = "suppressed"
+)
+
+// DedupConfig holds the configuration of a DedupHandler.
+type DedupConfig struct {
+	// Limit is the number of records passed per call site and interval. Further records
+	// of the call site are suppressed until the interval ends.
+	Limit int
+	// Interval is the duration of the window in which Limit applies. A non-positive
+	// interval is replaced by DefaultDedupConfig.Interval, as a window never ending
+	// would suppress the call sites for good.
+	Interval time.Duration
+}
+
+// DefaultDedupConfig provides default configuration values.
+var DefaultDedupConfig = DedupConfig{
+	Limit:    10,
+	Interval: time.Minute,
+}
+
+// DedupHandler is a slog.Handler that rate limits records per call site. Records are keyed
+// by the caller frame of their PC, and repeats beyond the limit are suppressed until the
+// interval ends. Once a window with suppressed records ends, a summary record such as
+// "suppressed 1234 similar messages from pkg/file.go:42" is emitted at the highest level
+// suppressed, on the next record handled, by a background sweep run every interval, or on
+// Flush. Records without PC are not limited. Close stops the background sweep.
+type DedupHandler struct {
+	next  slog.Handler
+	state *dedupState
+}
+
+// dedupState is the state shared by a handler and the handlers derived from it.
+type dedupState struct {
+	config    DedupConfig
+	now       func() time.Time
+	mu        sync.Mutex
+	frames    map[uintptr]stacktrace.Frame
+	sites     map[stacktrace.Frame]*dedupSite
+	lastSweep time.Time
+	stop      chan struct{} // Closed to stop the background sweep, nil if not running.
+	stopped   chan struct{} // Closed once the background sweep returned.
+	closeOnce sync.Once
+}
+
+// dedupSite is the window of a call site.
+type dedupSite struct {
+	frame      stacktrace.Frame
+	pc         uintptr
+	start      time.Time
+	count      int
+	suppressed int
+	level      slog.Level
+	next       slog.Handler // Handler of the last suppressed record, receiving the summary.
+}
+
+// summary is a pending summary record.
+type summary struct {
+	next   slog.Handler
+	record slog.Record
+}
+
+// NewDedupHandler creates a handler passing the records within the limits to next, and
+// starts the background sweep of the ended windows. If config is nil, DefaultDedupConfig
+// is used. The handler must be closed with Close to stop the sweep.
+func NewDedupHandler(next slog.Handler, config *DedupConfig) *DedupHandler {
+	if config == nil {
+		config = &DefaultDedupConfig
+	}
+	state := &dedupState{
+		config: *config,
+		now:    time.Now,
+		frames: make(map[uintptr]stacktrace.Frame),
+		sites:  make(map[stacktrace.Frame]*dedupSite),
+	}
+	if state.config.Interval <= 0 {
+		state.config.Interval = DefaultDedupConfig.Interval
+	}
+	if state.config.Interval > 0 {
+		state.stop = make(chan struct{})
+		state.stopped = make(chan struct{})
+		go state.run()
+	}
+	return &DedupHandler{next: next, state: state}
+}
+
+// Enabled reports whether the next handler handles records at the level.
+func (handler *DedupHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return handler.next.Enabled(ctx, level)
+}
+
+// Handle passes the record to the next handler unless its call site exceeded the limit,
+// after emitting the summaries of the ended windows.
+func (handler *DedupHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.PC == 0 {
+		return handler.next.Handle(ctx, record)
+	}
+	state := handler.state
+	now := state.now()
+
+	state.mu.Lock()
+	summaries := state.sweep(now)
+	site := state.site(record.PC, now)
+	if now.Sub(site.start) >= state.config.Interval {
+		if site.suppressed > 0 {
+			summaries = append(summaries, site.summary(now))
+		}
+		*site = dedupSite{frame: site.frame, pc: site.pc, start: now}
+	}
+	site.count++
+	suppress := site.count > state.config.Limit
+	if suppress {
+		if site.suppressed == 0 || record.Level > site.level {
+			site.level = record.Level
+		}
+		site.suppressed++
+		site.next = handler.next
+	}
+	state.mu.Unlock()
+
+	err := emit(ctx, summaries)
+	if suppress {
+		return err
+	}
+	return errors.Join(err, handler.next.Handle(ctx, record))
+}
+
+// Flush emits the summaries of all the call sites with suppressed records and starts
+// new windows for them. It is typically called on shutdown.
+func (handler *DedupHandler) Flush(ctx context.Context) error {
+	state := handler.state
+	now := state.now()
+	var summaries []summary
+	state.mu.Lock()
+	for key, site := range state.sites {
+		if site.suppressed > 0 {
+			summaries = append(summaries, site.summary(now))
+		}
+		delete(state.sites, key)
+	}
+	state.mu.Unlock()
+	return emit(ctx, summaries)
+}
+
+// Close stops the background sweep and emits the pending summaries as Flush. Handlers
+// derived with WithAttrs and WithGroup share the sweep, so closing any of them stops it.
+func (handler *DedupHandler) Close() error {
+	state := handler.state
+	state.closeOnce.Do(func() {
+		if state.stop != nil {
+			close(state.stop)
+			<-state.stopped
+		}
+	})
+	return handler.Flush(context.Background())
+}
+
+// WithAttrs returns a handler whose next handler has the attributes, sharing the limits.
+func (handler *DedupHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &DedupHandler{next: handler.next.WithAttrs(attrs), state: handler.state}
+}
+
+// WithGroup returns a handler whose next handler has the group, sharing the limits.
+func (handler *DedupHandler) WithGroup(name string) slog.Handler {
+	return &DedupHandler{next: handler.next.WithGroup(name), state: handler.state}
+}
+
+// site returns the window of the call site of pc, starting a new one if none is running.
+// The caller must hold the lock.
+func (state *dedupState) site(pc uintptr, now time.Time) *dedupSite {
+	frame, ok := state.frames[pc]
+	if !ok {
+		frame = stacktrace.FrameOf(pc)
+		frame.PC = 0 // Records from any PC of the same line are similar.
+		state.frames[pc] = frame
+	}
+	site, ok := state.sites[frame]
+	if !ok {
+		site = &dedupSite{frame: frame, pc: pc, start: now}
+		state.sites[frame] = site
+	}
+	return site
+}
+
+// run sweeps the ended windows every interval and emits their summaries until stopped.
+// Errors of the handlers receiving the summaries are dropped, as there is no caller to
+// return them to.
+func (state *dedupState) run() {
+	defer close(state.stopped)
+	ticker := time.NewTicker(state.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-state.stop:
+			return
+		case <-ticker.C:
+		}
+		state.mu.Lock()
+		summaries := state.expire(state.now())
+		state.mu.Unlock()
+		_ = emit(context.Background(), summaries)
+	}
+}
+
+// sweep ends the windows older than the interval, at most once per interval, and returns
+// the summaries of those with suppressed records. The caller must hold the lock.
+func (state *dedupState) sweep(now time.Time) []summary {
+	if now.Sub(state.lastSweep) < state.config.Interval {
+		return nil
+	}
+	return state.expire(now)
+}
+
+// expire ends the windows older than the interval and returns the summaries of those with
+// suppressed records. The caller must hold the lock.
+func (state *dedupState) expire(now time.Time) []summary {
+	state.lastSweep = now
+	var summaries []summary
+	for key, site := range state.sites {
+		if now.Sub(site.start) < state.config.Interval {
+			continue
+		}
+		if site.suppressed > 0 {
+			summaries = append(summaries, site.summary(now))
+		}
+		delete(state.sites, key)
+	}
+	return summaries
+}
+
+// summary returns the summary record of the suppressed records of the site.
+func (site *dedupSite) summary(now time.Time) summary {
+	record := slog.NewRecord(now, site.level, fmt.Sprintf("suppressed %d similar messages from %s:%d",
+		site.suppressed, shortFile(site.frame.File), site.frame.Line), site.pc)
+	record.AddAttrs(slog.Int(SuppressedKey, site.suppressed))
+	return summary{next: site.next, record: record}
+}
+
+// emit passes the summary records to their handlers.
+func emit(ctx context.Context, summaries []summary) error {
+	var errs []error
+	for _, summary := range summaries {
+		if summary.next.Enabled(ctx, summary.record.Level) {
+			errs = append(errs, summary.next.Handle(ctx, summary.record))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shortFile returns the last directory and the name of a file, such as "pkg/file.go".
+func shortFile(file string) string {
+	dir, name := filepath.Split(file)
+	if dir = filepath.Base(filepath.Clean(dir)); dir == "." || dir == string(filepath.Separator) {
+		return name
+	}
+	return dir + "/" + name
+}
@@ -0,0 +1,221 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// decodeRecords decodes the JSON records written to buf.
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("decoding record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestDedupHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupConfig{Limit: 2, Interval: time.Minute})
+	defer handler.Close()
+	now := time.Date(2024, 10, 6, 12, 0, 0, 0, time.UTC)
+	handler.state.now = func() time.Time { return now }
+	logger := slog.New(handler)
+
+	for i := 0; i < 5; i++ {
+		logger.Error("connection refused", "attempt", i)
+	}
+	logger.Warn("other call site")
+	if records := decodeRecords(t, &buf); len(records) != 3 {
+		t.Fatalf("%d records passed, want 3:\n%s", len(records), buf.String())
+	}
+
+	buf.Reset()
+	now = now.Add(time.Minute)
+	logger.Info("next window")
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 {
+		t.Fatalf("%d records after the window, want the summary and the record:\n%s", len(records), buf.String())
+	}
+	summary := records[0]
+	if !strings.HasPrefix(summary["msg"].(string), "suppressed 3 similar messages from logging/dedup_test.go:") {
+		t.Errorf("summary message = %q", summary["msg"])
+	}
+	if summary["level"] != "ERROR" || summary[SuppressedKey] != float64(3) {
+		t.Errorf("summary = %v, want level ERROR with 3 suppressed", summary)
+	}
+}
+
+func TestDedupHandlerSameSiteNewWindow(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupConfig{Limit: 1, Interval: time.Second})
+	defer handler.Close()
+	now := time.Date(2024, 10, 6, 12, 0, 0, 0, time.UTC)
+	handler.state.now = func() time.Time { return now }
+	logger := slog.New(handler).With("service", "api")
+
+	for i := 0; i < 6; i++ {
+		now = now.Add(400 * time.Millisecond)
+		logger.Error("timeout")
+	}
+	if err := handler.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	var passed, suppressed int
+	for _, record := range decodeRecords(t, &buf) {
+		if record["service"] != "api" {
+			t.Errorf("record lost the handler attributes: %v", record)
+		}
+		if n, ok := record[SuppressedKey].(float64); ok {
+			suppressed += int(n)
+		} else {
+			passed++
+		}
+	}
+	if passed != 2 || suppressed != 4 {
+		t.Errorf("%d records passed and %d summarized, want 2 and 4", passed, suppressed)
+	}
+}
+
+func TestDedupHandlerFlush(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupConfig{Limit: 1, Interval: time.Hour})
+	defer handler.Close()
+	logger := slog.New(handler)
+	for i := 0; i < 4; i++ {
+		logger.Warn("disk almost full")
+	}
+	if err := handler.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	records := decodeRecords(t, &buf)
+	if len(records) != 2 || records[1][SuppressedKey] != float64(3) || records[1]["level"] != "WARN" {
+		t.Errorf("records = %v, want the record and a summary of 3", records)
+	}
+
+	buf.Reset()
+	if err := handler.Flush(context.Background()); err != nil || buf.Len() != 0 {
+		t.Errorf("second Flush() = %v and wrote %q, want nothing", err, buf.String())
+	}
+}
+
+// lockedBuffer is a buffer safe for concurrent use.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (buffer *lockedBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buf.Write(p)
+}
+
+func (buffer *lockedBuffer) String() string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buf.String()
+}
+
+func TestDedupHandlerSweep(t *testing.T) {
+	var buf lockedBuffer
+	handler := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupConfig{Limit: 1, Interval: 10 * time.Millisecond})
+	logger := slog.New(handler)
+	for i := 0; i < 3; i++ {
+		logger.Error("connection refused")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "suppressed 2 similar messages") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no summary emitted without further records:\n%s", buf.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("second Close() = %v", err)
+	}
+	select {
+	case <-handler.state.stopped:
+	default:
+		t.Error("Close did not stop the background sweep")
+	}
+}
+
+func TestDedupHandlerCloseFlushes(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupConfig{Limit: 1, Interval: time.Hour})
+	logger := slog.New(handler)
+	for i := 0; i < 3; i++ {
+		logger.Warn("disk almost full")
+	}
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if records := decodeRecords(t, &buf); len(records) != 2 || records[1][SuppressedKey] != float64(2) {
+		t.Errorf("records = %v, want the record and a summary of 2", records)
+	}
+}
+
+func TestDedupHandlerWithoutPC(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewDedupHandler(slog.NewTextHandler(&buf, nil), &DedupConfig{Limit: 1, Interval: time.Hour})
+	defer handler.Close()
+	for i := 0; i < 3; i++ {
+		record := slog.NewRecord(time.Now(), slog.LevelInfo, fmt.Sprintf("message %d", i), 0)
+		if err := handler.Handle(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "message"); n != 3 {
+		t.Errorf("%d records without PC passed, want 3", n)
+	}
+}
+
+func TestDedupHandlerNonPositiveInterval(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewDedupHandler(slog.NewJSONHandler(&buf, nil), &DedupConfig{Limit: 1})
+	defer handler.Close()
+	if handler.state.config.Interval != DefaultDedupConfig.Interval {
+		t.Errorf("interval = %v, want the default %v", handler.state.config.Interval, DefaultDedupConfig.Interval)
+	}
+	logger := slog.New(handler)
+	for i := 0; i < 3; i++ {
+		logger.Warn("disk almost full")
+	}
+	if records := decodeRecords(t, &buf); len(records) != 1 {
+		t.Errorf("%d records passed without interval, want 1:\n%s", len(records), buf.String())
+	}
+}
+
+func TestShortFile(t *testing.T) {
+	tests := map[string]string{
+		"/src/project/pkg/file.go": "pkg/file.go",
+		"/file.go":                 "file.go",
+		"file.go":                  "file.go",
+	}
+	for file, want := range tests {
+		if got := shortFile(file); got != want {
+			t.Errorf("shortFile(%q) = %q, want %q", file, got, want)
+		}
+	}
+}
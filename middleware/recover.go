@@ -0,0 +1,199 @@
+// Package middleware provides HTTP middlewares built on the stacktrace package.
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+// RecoverConfig holds the configuration of the Recover middleware.
+type RecoverConfig struct {
+	// Dev renders the panic value and the stack trace as HTML in the response body.
+	// Otherwise, the body only holds an opaque ID referring to the logged record.
+	Dev bool
+	// Logger receives the recovered panics. The default logger is used if nil.
+	Logger *slog.Logger
+	// Write writes the response of a recovered panic if set, replacing the default body.
+	// It is only called if the handler did not write the response header yet.
+	Write func(w http.ResponseWriter, r *http.Request, recovered *Recovered)
+}
+
+// DefaultRecoverConfig provides default configuration values.
+var DefaultRecoverConfig = RecoverConfig{}
+
+// Recovered is a panic recovered by the Recover middleware.
+type Recovered struct {
+	// ID identifies the panic in the logs and in the response.
+	ID string
+	// Panic holds the panic value and the stack trace of the handler.
+	Panic *stacktrace.PanicError
+}
+
+// Recover recovers the panics of next with DefaultRecoverConfig.
+func Recover(next http.Handler) http.Handler {
+	return DefaultRecoverConfig.Recover(next)
+}
+
+// Recover returns a middleware recovering the panics of next. A panic is logged at error
+// level with its ID, its value, the structured stack trace and the request metadata, then
+// a 500 response is written unless the response header was already written.
+// Panics with http.ErrAbortHandler are not recovered, so that the server aborts the
+// response silently as documented by net/http.
+func (config *RecoverConfig) Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &responseWriter{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			recovered := &Recovered{
+				ID: newID(),
+				Panic: &stacktrace.PanicError{
+					Value: value,
+					Kind:  stacktrace.ClassifyPanic(value),
+					Stack: stacktrace.NewPanicStackTrace(nil),
+				},
+			}
+			config.log(r, recovered)
+			if !writer.wroteHeader {
+				config.write(w, r, recovered)
+			}
+		}()
+		next.ServeHTTP(writer, r)
+	})
+}
+
+// log writes the recovered panic and the request metadata to the logger.
+func (config *RecoverConfig) log(r *http.Request, recovered *Recovered) {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.LogAttrs(context.WithoutCancel(r.Context()), slog.LevelError, "panic recovered",
+		slog.String("id", recovered.ID),
+		slog.String("panic", fmt.Sprint(recovered.Panic.Value)),
+		slog.String("panic_kind", recovered.Panic.Kind.String()),
+		slog.String("method", r.Method),
+		slog.String("url", r.URL.String()),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("user_agent", r.UserAgent()),
+		slog.Any("stack", recovered.Panic.Stack.Frames()),
+	)
+}
+
+// write writes the 500 response of the recovered panic.
+func (config *RecoverConfig) write(w http.ResponseWriter, r *http.Request, recovered *Recovered) {
+	if config.Write != nil {
+		config.Write(w, r, recovered)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !config.Dev {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Internal Server Error\nID: %s\n", recovered.ID)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = devTemplate.Execute(w, struct {
+		ID     string
+		Panic  string
+		Method string
+		URL    string
+		Frames stacktrace.Frames
+	}{recovered.ID, fmt.Sprint(recovered.Panic.Value), r.Method, r.URL.String(), recovered.Panic.Stack.Frames()})
+}
+
+// devTemplate renders a recovered panic in dev mode.
+var devTemplate = template.Must(template.New("panic").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>panic: {{.Panic}}</title></head>
+<body style="font-family: monospace">
+<h1>panic: {{.Panic}}</h1>
+<p>{{.Method}} {{.URL}} &middot; ID {{.ID}}</p>
+<ol>
+{{- range .Frames}}
+<li><strong>{{.Function}}</strong><br>{{.File}}:{{.Line}}</li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+// newID returns a random ID of 16 hexadecimal digits.
+func newID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// responseWriter records whether the response header was written.
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader records the header as written, unless informational, and writes it.
+func (writer *responseWriter) WriteHeader(statusCode int) {
+	if statusCode >= http.StatusOK {
+		writer.wroteHeader = true
+	}
+	writer.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records the header as written and writes the data.
+func (writer *responseWriter) Write(data []byte) (int, error) {
+	writer.wroteHeader = true
+	return writer.ResponseWriter.Write(data)
+}
+
+// Flush records the header as written and flushes the wrapped writer, if it supports it.
+func (writer *responseWriter) Flush() {
+	writer.wroteHeader = true
+	_ = http.NewResponseController(writer.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection of the wrapped writer, if it supports it. The response
+// of a recovered panic is not written to a hijacked connection.
+func (writer *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := http.NewResponseController(writer.ResponseWriter).Hijack()
+	if err == nil {
+		writer.wroteHeader = true
+	}
+	return conn, buf, err
+}
+
+// ReadFrom records the header as written and copies the data to the wrapped writer, using
+// its io.ReaderFrom implementation if any.
+func (writer *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	writer.wroteHeader = true
+	return io.Copy(writer.ResponseWriter, r)
+}
+
+// Push initiates an HTTP/2 server push if the wrapped writer supports it.
+func (writer *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := writer.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (writer *responseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
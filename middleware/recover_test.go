@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic("<nil map>")
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name        string
+		dev         bool
+		contentType string
+	}{
+		{"prod", false, "text/plain; charset=utf-8"},
+		{"dev", true, "text/html; charset=utf-8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			config := &RecoverConfig{Dev: test.dev, Logger: slog.New(slog.NewJSONHandler(&logs, nil))}
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/orders?id=7", nil)
+			config.Recover(http.HandlerFunc(panickingHandler)).ServeHTTP(recorder, request)
+
+			var record struct {
+				Msg   string
+				ID    string
+				Panic string
+				Kind  string `json:"panic_kind"`
+				URL   string
+				Stack []struct{ Function string }
+			}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("decoding log record: %v", err)
+			}
+			if record.Msg != "panic recovered" || record.Panic != "<nil map>" || record.Kind != "string" || record.URL != "/orders?id=7" || len(record.ID) != 16 {
+				t.Errorf("log record = %+v", record)
+			}
+			if len(record.Stack) == 0 || record.Stack[0].Function != "middleware.panickingHandler" {
+				t.Errorf("logged stack does not start at the panicking handler: %+v", record.Stack)
+			}
+
+			body := recorder.Body.String()
+			if recorder.Code != http.StatusInternalServerError || recorder.Header().Get("Content-Type") != test.contentType {
+				t.Errorf("response = %d %q", recorder.Code, recorder.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(body, record.ID) {
+				t.Errorf("response body does not contain the ID %s:\n%s", record.ID, body)
+			}
+			if leaks := strings.Contains(body, "panickingHandler"); leaks != test.dev {
+				t.Errorf("response body contains the stack = %t, want %t:\n%s", leaks, test.dev, body)
+			}
+			if test.dev && !strings.Contains(body, "panic: &lt;nil map&gt;") {
+				t.Errorf("dev response body does not escape the panic value:\n%s", body)
+			}
+		})
+	}
+}
+
+func TestRecoverAfterWriteHeader(t *testing.T) {
+	config := &RecoverConfig{Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))}
+	recorder := httptest.NewRecorder()
+	handler := config.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	}))
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusAccepted || recorder.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want the written header without body", recorder.Code, recorder.Body)
+	}
+}
+
+func TestRecoverOptionalInterfaces(t *testing.T) {
+	config := &RecoverConfig{Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))}
+	recorder := httptest.NewRecorder()
+	handler := config.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("streamed")); err != nil {
+			t.Errorf("ReadFrom() = %v", err)
+		}
+		w.(http.Flusher).Flush()
+		if err := w.(http.Pusher).Push("/style.css", nil); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Push() = %v, want http.ErrNotSupported", err)
+		}
+		if _, _, err := w.(http.Hijacker).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("Hijack() = %v, want http.ErrNotSupported", err)
+		}
+		panic("after flush")
+	}))
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !recorder.Flushed || recorder.Code != http.StatusOK || recorder.Body.String() != "streamed" {
+		t.Errorf("response = %d %q flushed %v, want the flushed body without the panic response", recorder.Code, recorder.Body, recorder.Flushed)
+	}
+}
+
+func TestRecoverHijack(t *testing.T) {
+	config := &RecoverConfig{Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))}
+	server := httptest.NewServer(config.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack() = %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = buf.Flush()
+		panic("after hijack")
+	})))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("http.Get() = %v", err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK || string(body) != "hijacked" {
+		t.Errorf("response = %d %q, want the response written to the hijacked connection", response.StatusCode, body)
+	}
+}
+
+func TestRecoverCustomWrite(t *testing.T) {
+	config := &RecoverConfig{
+		Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)),
+		Write: func(w http.ResponseWriter, r *http.Request, recovered *Recovered) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(recovered.ID))
+		},
+	}
+	recorder := httptest.NewRecorder()
+	config.Recover(http.HandlerFunc(panickingHandler)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Body.Len() != 16 {
+		t.Errorf("response = %d %q, want the custom response", recorder.Code, recorder.Body)
+	}
+}
+
+func TestRecoverAbortHandler(t *testing.T) {
+	var logs bytes.Buffer
+	config := &RecoverConfig{Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	handler := config.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if value := recover(); value != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler to be re-panicked", value)
+		}
+		if logs.Len() != 0 {
+			t.Errorf("http.ErrAbortHandler was logged: %s", logs.String())
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,149 @@
+package stacktrace
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxAncestors is the maximum number of creation sites kept for a goroutine.
+	maxAncestors = 16
+)
+
+var (
+	// ancestry maps the ID of each goroutine started by Go to its creation chain.
+	ancestry sync.Map
+)
+
+// PanicError is a recovered panic value with the stack trace captured at the panic site.
+type PanicError struct {
+	Value any         // Value passed to panic.
+	Kind  PanicKind   // Classification of the value.
+	Stack *StackTrace // Stack trace of the panicking goroutine from the panic site, including its creation chain.
+}
+
+// Error returns the panic value. The stack trace is available through StackTrace.
+func (panicError *PanicError) Error() string {
+	return "panic: " + fmt.Sprint(panicError.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (panicError *PanicError) Unwrap() error {
+	err, _ := panicError.Value.(error)
+	return err
+}
+
+// StackTrace returns the stack trace captured at the panic site.
+func (panicError *PanicError) StackTrace() *StackTrace {
+	return panicError.Stack
+}
+
+// Go runs fn in a new goroutine and records the calling goroutine's stack as its creation site.
+// Stack traces captured while fn runs, including in goroutines it starts with Go, carry the
+// whole creation chain. If fn panics, the goroutine re-panics with the value and the full chain
+// of the panic stack trace, so the crash output shows the child frames followed by every
+// creation site.
+func Go(fn func()) {
+	chain := spawnChain(1)
+	go func() {
+		id := currentGoroutineID()
+		ancestry.Store(id, chain)
+		defer ancestry.Delete(id)
+		if panicError := catch(fn); panicError != nil {
+			panic(&goPanic{panicError})
+		}
+	}()
+}
+
+// goPanic is the value re-panicked by Go, whose message holds the full chain for the crash output.
+type goPanic struct {
+	*PanicError
+}
+
+// Error returns the panic value followed by the full causal chain of the stack trace.
+func (panicError *goPanic) Error() string {
+	return fmt.Sprintf("%v\n%s", panicError.Value, panicError.Stack.Chain())
+}
+
+// catch calls fn and converts a panic into a *PanicError captured at the panic site.
+func catch(fn func()) (panicError *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicError = &PanicError{Value: r, Kind: ClassifyPanic(r), Stack: NewPanicStackTrace(nil)}
+		}
+	}()
+	fn()
+	return nil
+}
+
+// spawnChain returns the creation chain for a goroutine started by the caller of spawnChain,
+// skipping skip frames above that caller.
+func spawnChain(skip int) []Frames {
+	chain := []Frames{callers(skip+1, DefaultConfig.BufferSize).filter()}
+	chain = append(chain, lookupAncestors(currentGoroutineID())...)
+	if len(chain) > maxAncestors {
+		chain = chain[:maxAncestors]
+	}
+	return chain
+}
+
+// lookupAncestors returns the creation chain recorded for the goroutine with the given ID.
+func lookupAncestors(id int64) []Frames {
+	if id == 0 {
+		return nil
+	}
+	if chain, ok := ancestry.Load(id); ok {
+		return chain.([]Frames)
+	}
+	return nil
+}
+
+// currentGoroutineID returns the ID of the calling goroutine.
+func currentGoroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	return parseGoroutineID(string(buf[:n]))
+}
+
+// parseGoroutineID extracts the goroutine ID from a "goroutine N [state]:" header.
+// It returns 0 if the header is missing or malformed.
+func parseGoroutineID(raw string) int64 {
+	rest, ok := strings.CutPrefix(raw, "goroutine ")
+	if !ok {
+		return 0
+	}
+	if i := strings.IndexByte(rest, ' '); i >= 0 {
+		rest = rest[:i]
+	}
+	id, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// Ancestors returns the creation-site stacks recorded by Go, nearest first.
+func (stackTrace *StackTrace) Ancestors() []Frames {
+	return stackTrace.ancestors
+}
+
+// Chain returns the filtered frames followed by each creation site recorded by Go, with
+// recursion collapsed as by Frames.String.
+func (stackTrace *StackTrace) Chain() string {
+	return stackTrace.CollapsedChain(DefaultCollapseRepeats)
+}
+
+// CollapsedChain returns the chain of Chain with the runs repeated at least minRepeats times
+// collapsed as by Frames.CollapsedString. A minRepeats lower than 2 renders every frame.
+func (stackTrace *StackTrace) CollapsedChain(minRepeats int) string {
+	var builder strings.Builder
+	builder.WriteString(stackTrace.frames.CollapsedString(minRepeats))
+	for _, frames := range stackTrace.ancestors {
+		builder.WriteString("\ncreated at\n")
+		builder.WriteString(frames.CollapsedString(minRepeats))
+	}
+	return builder.String()
+}
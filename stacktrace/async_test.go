@@ -0,0 +1,117 @@
+package stacktrace
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func spawnGrandchild(done chan<- *StackTrace) {
+	Go(func() {
+		done <- NewStackTrace(&Config{BufferSize: 4096, SkipFrames: 0})
+	})
+}
+
+func spawnChild(done chan<- *StackTrace) {
+	Go(func() {
+		spawnGrandchild(done)
+	})
+}
+
+func TestGo(t *testing.T) {
+	done := make(chan *StackTrace, 1)
+	spawnChild(done)
+	st := <-done
+
+	ancestors := st.Ancestors()
+	if len(ancestors) != 2 {
+		t.Fatalf("StackTrace.Ancestors() returned %d creation sites, want 2", len(ancestors))
+	}
+	if !strings.Contains(ancestors[0].String(), "spawnGrandchild") {
+		t.Errorf("nearest creation site does not contain spawnGrandchild: %s", ancestors[0])
+	}
+	if !strings.Contains(ancestors[1].String(), "spawnChild") || !strings.Contains(ancestors[1].String(), "TestGo") {
+		t.Errorf("outer creation site does not contain spawnChild and TestGo: %s", ancestors[1])
+	}
+
+	chain := st.Chain()
+	if strings.Count(chain, "created at") != 2 {
+		t.Errorf("StackTrace.Chain() = %q, want 2 creation sites", chain)
+	}
+	if strings.Index(chain, "spawnGrandchild") > strings.Index(chain, "TestGo") {
+		t.Errorf("StackTrace.Chain() is not ordered nearest first: %q", chain)
+	}
+}
+
+func TestGoReleasesAncestry(t *testing.T) {
+	if st := NewStackTrace(nil); len(st.Ancestors()) != 0 {
+		t.Error("goroutine not started by Go has a creation chain")
+	}
+
+	ids := make(chan int64, 1)
+	Go(func() {
+		ids <- currentGoroutineID()
+	})
+	id := <-ids
+	// The entry is removed on the child goroutine once fn returns.
+	for i := 0; i < 1000 && lookupAncestors(id) != nil; i++ {
+		runtime.Gosched()
+	}
+	if lookupAncestors(id) != nil {
+		t.Error("creation chain was not released after the goroutine finished")
+	}
+}
+
+func TestCatch(t *testing.T) {
+	t.Run("NoPanic", func(t *testing.T) {
+		if panicError := catch(func() {}); panicError != nil {
+			t.Errorf("catch returned %v, want nil", panicError)
+		}
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		cause := errors.New("boom")
+		panicError := catch(func() { panic(cause) })
+		if panicError == nil {
+			t.Fatal("catch returned nil for a panicking function")
+		}
+		if panicError.Value != cause {
+			t.Errorf("PanicError.Value = %v, want %v", panicError.Value, cause)
+		}
+		if !errors.Is(panicError, cause) {
+			t.Error("PanicError does not unwrap to the panic value")
+		}
+		if panicError.Error() != "panic: boom" {
+			t.Errorf("PanicError.Error() = %q, want %q", panicError.Error(), "panic: boom")
+		}
+		if crash := (&goPanic{panicError}).Error(); !strings.HasPrefix(crash, "boom\n") || !strings.Contains(crash, "TestCatch") {
+			t.Errorf("crash message = %q, want the value followed by the stack", crash)
+		}
+		if !strings.Contains(panicError.Stack.Frames().String(), "TestCatch") {
+			t.Error("PanicError.Stack does not contain the panicking test")
+		}
+	})
+}
+
+func TestParseGoroutineID(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+	}{
+		{"goroutine 42 [running]:\nmain.main()", 42},
+		{"goroutine 7 [chan receive, 2 minutes]:", 7},
+		{"goroutine x [running]:", 0},
+		{"", 0},
+	}
+
+	for _, tc := range testCases {
+		if id := parseGoroutineID(tc.input); id != tc.expected {
+			t.Errorf("parseGoroutineID(%q) = %d, want %d", tc.input, id, tc.expected)
+		}
+	}
+
+	if currentGoroutineID() == 0 {
+		t.Error("currentGoroutineID() returned 0")
+	}
+}
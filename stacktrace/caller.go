@@ -0,0 +1,38 @@
+package stacktrace
+
+// Caller returns the frame of the caller of Caller, skipping skip additional frames.
+// It does not capture the raw stack trace text and normalizes the frame like
+// StackTrace.Frames. The zero Frame is returned if the frame is unknown or invalid.
+func Caller(skip int) Frame {
+	frames := callers(skip+1, 1).filter() // +1 to skip Caller
+	if len(frames) == 0 {
+		return Frame{}
+	}
+	return frames[0]
+}
+
+// CallerFunction returns the normalized function name of the caller of CallerFunction,
+// skipping skip additional frames, or an empty string if unknown.
+func CallerFunction(skip int) string {
+	return Caller(skip + 1).Function // +1 to skip CallerFunction
+}
+
+// Callers returns at most n frames starting at the caller of Callers, skipping skip
+// additional frames. Invalid frames are filtered out as in StackTrace.Frames.
+func Callers(skip, n int) Frames {
+	return callers(skip+1, n).filter() // +1 to skip Callers
+}
+
+// FrameOf returns the frame of a program counter returned by runtime.Callers, such as
+// slog.Record.PC, normalized like StackTrace.Frames. For inlined calls, the frame of the
+// innermost function is returned. The zero Frame is returned if the frame is unknown or invalid.
+func FrameOf(pc uintptr) Frame {
+	if pc == 0 {
+		return Frame{}
+	}
+	frames := framesOf([]uintptr{pc}).filter()
+	if len(frames) == 0 {
+		return Frame{}
+	}
+	return frames[0]
+}
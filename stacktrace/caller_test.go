@@ -0,0 +1,69 @@
+package stacktrace
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func callerHelper() Frame {
+	return Caller(1)
+}
+
+func TestCaller(t *testing.T) {
+	frame := Caller(0)
+	if frame.Function != "stacktrace.TestCaller" {
+		t.Errorf("Caller(0).Function = %q, want %q", frame.Function, "stacktrace.TestCaller")
+	}
+	if !strings.HasSuffix(frame.File, "caller_test.go") || frame.Line < 1 {
+		t.Errorf("Caller(0) returned an invalid location: %+v", frame)
+	}
+
+	if frame := callerHelper(); frame.Function != "stacktrace.TestCaller" {
+		t.Errorf("Caller(1).Function = %q, want %q", frame.Function, "stacktrace.TestCaller")
+	}
+
+	if frame := Caller(1000); frame != (Frame{}) {
+		t.Errorf("Caller(1000) = %+v, want the zero Frame", frame)
+	}
+}
+
+func TestCallerFunction(t *testing.T) {
+	if function := CallerFunction(0); function != "stacktrace.TestCallerFunction" {
+		t.Errorf("CallerFunction(0) = %q, want %q", function, "stacktrace.TestCallerFunction")
+	}
+	if function := CallerFunction(1000); function != "" {
+		t.Errorf("CallerFunction(1000) = %q, want empty string", function)
+	}
+}
+
+func TestCallers(t *testing.T) {
+	frames := Callers(0, 2)
+	if len(frames) != 2 {
+		t.Fatalf("Callers(0, 2) returned %d frames, want 2", len(frames))
+	}
+	if frames[0].Function != "stacktrace.TestCallers" || frames[1].Function != "testing.tRunner" {
+		t.Errorf("Callers(0, 2) = %v", frames)
+	}
+	if frames := Callers(0, 0); len(frames) != 0 {
+		t.Errorf("Callers(0, 0) returned %d frames, want 0", len(frames))
+	}
+}
+
+func TestFrameOf(t *testing.T) {
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	frame := FrameOf(pcs[0])
+	if frame.Function != "stacktrace.TestFrameOf" || !strings.HasSuffix(frame.File, "caller_test.go") {
+		t.Errorf("FrameOf() = %+v, want the frame of TestFrameOf", frame)
+	}
+	if frame := FrameOf(0); frame != (Frame{}) {
+		t.Errorf("FrameOf(0) = %+v, want the zero Frame", frame)
+	}
+}
+
+func BenchmarkCaller(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Caller(0)
+	}
+}
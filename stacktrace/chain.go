@@ -0,0 +1,90 @@
+package stacktrace
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StackTracer is implemented by errors carrying the stack trace captured where they were created.
+type StackTracer interface {
+	StackTrace() *StackTrace
+}
+
+// FormatError renders an error chain Java-style: the error message and its stack, then a
+// "Caused by:" section for every wrapped error carrying a stack trace and for the innermost
+// cause, such as io.EOF, even without a stack trace. Frames shared with
+// the enclosing stack are elided as "... N common frames omitted". The branches of errors
+// joined with errors.Join are rendered indented as "Caused by (i of n):" sections. Recursion
+// is collapsed as by Frames.String.
+func FormatError(err error) string {
+	return FormatErrorCollapsed(err, DefaultCollapseRepeats)
+}
+
+// FormatErrorCollapsed renders an error chain as FormatError, with the runs of frames
+// repeated at least minRepeats times collapsed as by Frames.CollapsedString. A minRepeats
+// lower than 2 renders every frame.
+func FormatErrorCollapsed(err error, minRepeats int) string {
+	if err == nil {
+		return ""
+	}
+	var builder strings.Builder
+	writeErrorChain(&builder, err, "", "", nil, minRepeats)
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+// writeErrorChain writes the error with its stack, relative to the enclosing frames, and its causes.
+func writeErrorChain(builder *strings.Builder, err error, indent, label string, enclosing Frames, minRepeats int) {
+	fmt.Fprintf(builder, "%s%s%s\n", indent, label, err.Error())
+	if stackTracer, ok := err.(StackTracer); ok && stackTracer.StackTrace() != nil {
+		frames := stackTracer.StackTrace().Frames()
+		common := len(frames.CommonSuffix(enclosing))
+		if common == len(frames) && common > 0 {
+			common-- // Keep at least the frame that created the error
+		}
+		lines := frames[:len(frames)-common].collapsedLines(minRepeats, func(frame Frame) string {
+			return fmt.Sprintf("at %s (%s:%d)", frame.Function, frame.File, frame.Line)
+		})
+		for _, line := range lines {
+			fmt.Fprintf(builder, "%s\t%s\n", indent, line)
+		}
+		if common > 0 {
+			fmt.Fprintf(builder, "%s\t... %d common frames omitted\n", indent, common)
+		}
+		enclosing = frames
+	}
+
+	for next := err; ; {
+		switch wrapper := next.(type) {
+		case interface{ Unwrap() []error }:
+			causes := wrapper.Unwrap()
+			for i, cause := range causes {
+				if cause != nil {
+					writeErrorChain(builder, cause, indent+"\t", fmt.Sprintf("Caused by (%d of %d): ", i+1, len(causes)), enclosing, minRepeats)
+				}
+			}
+			return
+		case interface{ Unwrap() error }:
+			next = wrapper.Unwrap()
+			if next == nil {
+				return
+			}
+			if stackTracer, ok := next.(StackTracer); ok && stackTracer.StackTrace() != nil || isRootCause(next) {
+				writeErrorChain(builder, next, indent, "Caused by: ", enclosing, minRepeats)
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// isRootCause reports whether the error wraps no other error.
+func isRootCause(err error) bool {
+	switch wrapper := err.(type) {
+	case interface{ Unwrap() []error }:
+		return false
+	case interface{ Unwrap() error }:
+		return wrapper.Unwrap() == nil
+	}
+	return true
+}
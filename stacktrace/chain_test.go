@@ -0,0 +1,103 @@
+package stacktrace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type stackError struct {
+	msg   string
+	cause error
+	stack *StackTrace
+}
+
+func newStackError(msg string, cause error) error {
+	return &stackError{msg: msg, cause: cause, stack: NewStackTrace(&Config{BufferSize: 4096, SkipFrames: 1})}
+}
+
+func (err *stackError) Error() string           { return err.msg }
+func (err *stackError) Unwrap() error           { return err.cause }
+func (err *stackError) StackTrace() *StackTrace { return err.stack }
+
+func queryRow() error {
+	return newStackError("connection reset", nil)
+}
+
+func loadUser() error {
+	return newStackError("loading user", fmt.Errorf("query: %w", queryRow()))
+}
+
+func TestFormatError(t *testing.T) {
+	formatted := FormatError(loadUser())
+	lines := strings.Split(formatted, "\n")
+
+	if lines[0] != "loading user" || !strings.HasPrefix(lines[1], "\tat stacktrace.loadUser (") {
+		t.Errorf("outer error is not rendered first:\n%s", formatted)
+	}
+	causedBy := strings.Index(formatted, "\nCaused by: connection reset\n")
+	if causedBy < 0 {
+		t.Fatalf("cause is not rendered:\n%s", formatted)
+	}
+	if strings.Contains(formatted, "Caused by: query") {
+		t.Errorf("wrapper without stack is rendered as a cause:\n%s", formatted)
+	}
+
+	cause := formatted[causedBy:]
+	if !strings.Contains(cause, "\tat stacktrace.queryRow (") {
+		t.Errorf("cause stack is missing its own frames:\n%s", cause)
+	}
+	if strings.Contains(cause, "TestFormatError") || strings.Contains(cause, "loadUser") {
+		t.Errorf("frames shared with the outer stack are not elided:\n%s", cause)
+	}
+	if !regexp.MustCompile(`\t\.\.\. \d+ common frames omitted$`).MatchString(cause) {
+		t.Errorf("cause does not end with the omitted frame count:\n%s", cause)
+	}
+}
+
+func TestFormatErrorRootCause(t *testing.T) {
+	err := newStackError("reading config", fmt.Errorf("open: %w", io.EOF))
+	formatted := FormatError(err)
+
+	if !strings.HasSuffix(formatted, "\nCaused by: EOF") {
+		t.Errorf("innermost cause without stack is not rendered last:\n%s", formatted)
+	}
+	if strings.Contains(formatted, "Caused by: open") {
+		t.Errorf("wrapper without stack is rendered as a cause:\n%s", formatted)
+	}
+	if FormatError(fmt.Errorf("open: %w", io.EOF)) != "open: EOF\nCaused by: EOF" {
+		t.Errorf("FormatError of a wrapped error = %q", FormatError(fmt.Errorf("open: %w", io.EOF)))
+	}
+}
+
+func TestFormatErrorJoin(t *testing.T) {
+	err := fmt.Errorf("saving: %w", errors.Join(queryRow(), errors.New("disk full"), nil))
+	formatted := FormatError(err)
+
+	for _, expected := range []string{
+		"saving: connection reset\ndisk full\n",
+		"\tCaused by (1 of 2): connection reset\n\t\tat stacktrace.queryRow (",
+		"\tCaused by (2 of 2): disk full",
+	} {
+		if !strings.Contains(formatted, expected) {
+			t.Errorf("formatted error does not contain %q:\n%s", expected, formatted)
+		}
+	}
+}
+
+func TestFormatErrorPanic(t *testing.T) {
+	panicError := catch(func() { panic(errors.New("boom")) })
+	formatted := FormatError(panicError)
+	if !strings.Contains(formatted, "\tat stacktrace.TestFormatErrorPanic") {
+		t.Errorf("PanicError stack is not rendered:\n%s", formatted)
+	}
+	if FormatError(nil) != "" {
+		t.Error("FormatError(nil) is not empty")
+	}
+	if FormatError(errors.New("plain")) != "plain" {
+		t.Errorf("FormatError of a plain error = %q", FormatError(errors.New("plain")))
+	}
+}
@@ -0,0 +1,76 @@
+package stacktrace
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultCollapseRepeats is the default number of repetitions from which a run is collapsed.
+	DefaultCollapseRepeats = 3
+	// maxCyclePeriod is the maximum number of frames in a detected cycle.
+	maxCyclePeriod = 8
+)
+
+// CollapsedString returns the string representation of the frames with recursion collapsed.
+// A frame or a cycle of up to 8 frames, such as mutually recursive functions or middleware
+// chains, repeated at least minRepeats times in a row is rendered once, followed by a
+// "... N more frames of pkg.fn ..." line. Frames are compared by function name. A minRepeats
+// lower than 2 disables collapsing and renders every frame. String collapses runs of
+// DefaultCollapseRepeats.
+func (frames Frames) CollapsedString(minRepeats int) string {
+	return strings.Join(frames.collapsedLines(minRepeats, func(frame Frame) string {
+		return fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function)
+	}), "\n")
+}
+
+// collapsedLines renders every frame with line, replacing the repetitions of the runs
+// repeated at least minRepeats times by a "... N more frames of pkg.fn ..." line.
+func (frames Frames) collapsedLines(minRepeats int, line func(Frame) string) []string {
+	lines := make([]string, 0, len(frames))
+	for i := 0; i < len(frames); {
+		period, repeats := 1, 1
+		if minRepeats >= 2 {
+			period, repeats = frames.cycleAt(i)
+		}
+		if repeats < minRepeats || minRepeats < 2 {
+			lines = append(lines, line(frames[i]))
+			i++
+			continue
+		}
+		names := make([]string, period)
+		for j := range names {
+			lines = append(lines, line(frames[i+j]))
+			names[j] = frames[i+j].Function
+		}
+		lines = append(lines, fmt.Sprintf("... %d more frames of %s ...", (repeats-1)*period, strings.Join(names, " -> ")))
+		i += repeats * period
+	}
+	return lines
+}
+
+// cycleAt returns the period and repetition count of the longest run of a repeated
+// cycle starting at index start. The shortest period wins between runs of equal length.
+func (frames Frames) cycleAt(start int) (int, int) {
+	bestPeriod, bestRepeats := 1, 1
+	for period := 1; period <= maxCyclePeriod && start+2*period <= len(frames); period++ {
+		repeats := 1
+		for next := start + period; next+period <= len(frames) && frames.sameFunctions(start, next, period); next += period {
+			repeats++
+		}
+		if repeats > 1 && repeats*period > bestRepeats*bestPeriod {
+			bestPeriod, bestRepeats = period, repeats
+		}
+	}
+	return bestPeriod, bestRepeats
+}
+
+// sameFunctions reports whether the n frames at indexes a and b have the same function names.
+func (frames Frames) sameFunctions(a, b, n int) bool {
+	for i := 0; i < n; i++ {
+		if frames[a+i].Function != frames[b+i].Function {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package stacktrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func recursiveFrames(functions ...string) Frames {
+	frames := make(Frames, len(functions))
+	for i, function := range functions {
+		frames[i] = Frame{Function: function, File: "/src/" + function + ".go", Line: i + 1}
+	}
+	return frames
+}
+
+func TestFramesCollapsedString(t *testing.T) {
+	testCases := []struct {
+		name     string
+		frames   Frames
+		expected []string
+	}{
+		{
+			name:     "Recursion",
+			frames:   recursiveFrames("p.leaf", "p.walk", "p.walk", "p.walk", "p.walk", "p.walk", "main.main"),
+			expected: []string{"/src/p.leaf.go:1 p.leaf", "/src/p.walk.go:2 p.walk", "... 4 more frames of p.walk ...", "/src/main.main.go:7 main.main"},
+		},
+		{
+			name:     "Cycle",
+			frames:   recursiveFrames("p.a", "p.b", "p.a", "p.b", "p.a", "p.b", "main.main"),
+			expected: []string{"/src/p.a.go:1 p.a", "/src/p.b.go:2 p.b", "... 4 more frames of p.a -> p.b ...", "/src/main.main.go:7 main.main"},
+		},
+		{
+			name:     "BelowThreshold",
+			frames:   recursiveFrames("p.walk", "p.walk", "main.main"),
+			expected: []string{"/src/p.walk.go:1 p.walk", "/src/p.walk.go:2 p.walk", "/src/main.main.go:3 main.main"},
+		},
+		{
+			name:     "Empty",
+			frames:   nil,
+			expected: []string{""},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected := strings.Join(tc.expected, "\n")
+			if collapsed := tc.frames.CollapsedString(DefaultCollapseRepeats); collapsed != expected {
+				t.Errorf("Frames.CollapsedString() = %q, want %q", collapsed, expected)
+			}
+		})
+	}
+}
+
+func TestFramesCollapsedStringExpand(t *testing.T) {
+	frames := recursiveFrames("p.walk", "p.walk", "p.walk", "p.walk")
+	expanded := "/src/p.walk.go:1 p.walk\n/src/p.walk.go:2 p.walk\n/src/p.walk.go:3 p.walk\n/src/p.walk.go:4 p.walk"
+	if collapsed := frames.CollapsedString(0); collapsed != expanded {
+		t.Errorf("Frames.CollapsedString(0) = %q, want the expanded frames %q", collapsed, expanded)
+	}
+	if rendered := frames.String(); rendered != frames.CollapsedString(DefaultCollapseRepeats) {
+		t.Errorf("Frames.String() = %q, want the frames collapsed", rendered)
+	}
+}
+
+func TestFramesCollapsedStringDeepRecursion(t *testing.T) {
+	functions := make([]string, 0, 10002)
+	functions = append(functions, "p.leaf")
+	for i := 0; i < 10000; i++ {
+		functions = append(functions, "p.walk")
+	}
+	functions = append(functions, "main.main")
+
+	collapsed := recursiveFrames(functions...).CollapsedString(DefaultCollapseRepeats)
+	if lines := strings.Count(collapsed, "\n") + 1; lines != 4 {
+		t.Errorf("deep recursion rendered as %d lines, want 4", lines)
+	}
+	if !strings.Contains(collapsed, "... 9999 more frames of p.walk ...") {
+		t.Errorf("deep recursion not collapsed: %q", collapsed)
+	}
+}
+
+func TestCollapsedRenderers(t *testing.T) {
+	frames := recursiveFrames("p.leaf", "p.walk", "p.walk", "p.walk", "p.walk", "main.main")
+	stackTrace := &StackTrace{frames: frames, ancestors: []Frames{frames}}
+	err := &stackError{msg: "walk failed", stack: stackTrace}
+
+	rendered := map[string]string{
+		"Chain":       stackTrace.Chain(),
+		"FormatError": FormatError(err),
+	}
+	for name, text := range rendered {
+		if strings.Count(text, "... 3 more frames of p.walk ...") != strings.Count(text, "p.leaf.go") {
+			t.Errorf("%s did not collapse the recursion:\n%s", name, text)
+		}
+	}
+	if !strings.Contains(FormatError(err), "\n\t... 3 more frames of p.walk ...\n") {
+		t.Errorf("FormatError did not render the collapsed run as a stack line:\n%s", FormatError(err))
+	}
+
+	expanded := map[string]string{
+		"CollapsedChain(0)":       stackTrace.CollapsedChain(0),
+		"FormatErrorCollapsed(0)": FormatErrorCollapsed(err, 0),
+	}
+	for name, text := range expanded {
+		if strings.Contains(text, "more frames") || strings.Count(text, "p.walk.go") != 4*strings.Count(text, "p.leaf.go") {
+			t.Errorf("%s did not render every frame:\n%s", name, text)
+		}
+	}
+}
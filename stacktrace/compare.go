@@ -0,0 +1,71 @@
+package stacktrace
+
+// Equal reports whether both frames have the same function, file and line.
+func (frame Frame) Equal(other Frame) bool {
+	return frame.Function == other.Function && frame.File == other.File && frame.Line == other.Line
+}
+
+// Equal reports whether both collections hold equal frames in the same order.
+func (frames Frames) Equal(other Frames) bool {
+	if len(frames) != len(other) {
+		return false
+	}
+	for i := range frames {
+		if !frames[i].Equal(other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CommonPrefix returns the innermost frames shared by both collections.
+func (frames Frames) CommonPrefix(other Frames) Frames {
+	n := 0
+	for n < len(frames) && n < len(other) && frames[n].Equal(other[n]) {
+		n++
+	}
+	return frames[:n]
+}
+
+// CommonSuffix returns the outermost frames shared by both collections,
+// such as the common callers of two goroutines.
+func (frames Frames) CommonSuffix(other Frames) Frames {
+	n := 0
+	for n < len(frames) && n < len(other) && frames[len(frames)-1-n].Equal(other[len(other)-1-n]) {
+		n++
+	}
+	return frames[len(frames)-n:]
+}
+
+// Similarity returns a score between 0 and 1 of how similar both collections are,
+// based on the edit distance between their function names. Line numbers and files are
+// ignored, so stacks that differ only by a few frames, for example by recursion depth,
+// score close to 1. Two empty collections are identical.
+func (frames Frames) Similarity(other Frames) float64 {
+	longest := max(len(frames), len(other))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(frames.distance(other))/float64(longest)
+}
+
+// distance returns the Levenshtein distance between the function names of both collections.
+func (frames Frames) distance(other Frames) int {
+	previous := make([]int, len(other)+1)
+	current := make([]int, len(other)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(frames); i++ {
+		current[0] = i
+		for j := 1; j <= len(other); j++ {
+			cost := 1
+			if frames[i-1].Function == other[j-1].Function {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(other)]
+}
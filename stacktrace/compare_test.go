@@ -0,0 +1,84 @@
+package stacktrace
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	compareLeaf   = Frame{Function: "parser.parse", File: "/src/parser/parse.go", Line: 20}
+	compareMiddle = Frame{Function: "parser.(*Parser).Run", File: "/src/parser/run.go", Line: 8}
+	compareRoot   = Frame{Function: "main.main", File: "/src/main.go", Line: 12}
+)
+
+func TestFramesEqual(t *testing.T) {
+	frames := Frames{compareLeaf, compareRoot}
+	if !frames.Equal(Frames{compareLeaf, compareRoot}) {
+		t.Error("Frames.Equal returned false for identical frames")
+	}
+	if frames.Equal(Frames{compareRoot, compareLeaf}) {
+		t.Error("Frames.Equal returned true for frames in different order")
+	}
+	if frames.Equal(Frames{compareLeaf}) {
+		t.Error("Frames.Equal returned true for frames of different length")
+	}
+	moved := compareLeaf
+	moved.Line++
+	if frames.Equal(Frames{moved, compareRoot}) {
+		t.Error("Frames.Equal returned true for frames on different lines")
+	}
+	if !(Frames{}).Equal(nil) {
+		t.Error("Frames.Equal returned false for empty frames")
+	}
+}
+
+func TestFramesCommonPrefixSuffix(t *testing.T) {
+	a := Frames{compareLeaf, compareMiddle, compareRoot}
+	b := Frames{compareLeaf, compareRoot}
+
+	if prefix := a.CommonPrefix(b); !prefix.Equal(Frames{compareLeaf}) {
+		t.Errorf("Frames.CommonPrefix = %v, want [%v]", prefix, compareLeaf)
+	}
+	if suffix := a.CommonSuffix(b); !suffix.Equal(Frames{compareRoot}) {
+		t.Errorf("Frames.CommonSuffix = %v, want [%v]", suffix, compareRoot)
+	}
+	if suffix := a.CommonSuffix(a); !suffix.Equal(a) {
+		t.Errorf("Frames.CommonSuffix with itself = %v, want %v", suffix, a)
+	}
+	if prefix := a.CommonPrefix(nil); len(prefix) != 0 {
+		t.Errorf("Frames.CommonPrefix with nil = %v, want none", prefix)
+	}
+}
+
+func TestFramesSimilarity(t *testing.T) {
+	recursive := func(depth int) Frames {
+		frames := Frames{compareLeaf}
+		for i := 0; i < depth; i++ {
+			frames = append(frames, compareMiddle)
+		}
+		return append(frames, compareRoot)
+	}
+
+	testCases := []struct {
+		name     string
+		a, b     Frames
+		expected float64
+	}{
+		{"Identical", recursive(3), recursive(3), 1},
+		{"Empty", nil, Frames{}, 1},
+		{"OneEmpty", recursive(0), nil, 0},
+		{"RecursionDepth", recursive(8), recursive(6), 0.8},
+		{"Disjoint", Frames{compareLeaf}, Frames{compareRoot}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if similarity := tc.a.Similarity(tc.b); math.Abs(similarity-tc.expected) > 1e-9 {
+				t.Errorf("Frames.Similarity = %v, want %v", similarity, tc.expected)
+			}
+			if tc.a.Similarity(tc.b) != tc.b.Similarity(tc.a) {
+				t.Error("Frames.Similarity is not symmetric")
+			}
+		})
+	}
+}
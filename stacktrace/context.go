@@ -0,0 +1,93 @@
+package stacktrace
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CancelError is the cause recorded when a context created by this package is canceled,
+// holding the stack of the canceller. It is returned by context.Cause.
+type CancelError struct {
+	Cause error       // Cause passed to the cancel function, context.Canceled or context.DeadlineExceeded.
+	Stack *StackTrace // Stack of the canceller, or of the creator for a deadline.
+}
+
+// Error returns the cause followed by the location of the canceller.
+func (cancelError *CancelError) Error() string {
+	frames := cancelError.Stack.Frames()
+	if len(frames) == 0 {
+		return cancelError.Cause.Error()
+	}
+	return fmt.Sprintf("%s (by %s at %s:%d)", cancelError.Cause, frames[0].Function, frames[0].File, frames[0].Line)
+}
+
+// Unwrap returns the cause, so errors.Is(err, context.Canceled) holds for plain cancellations.
+func (cancelError *CancelError) Unwrap() error {
+	return cancelError.Cause
+}
+
+// StackTrace returns the stack of the canceller.
+func (cancelError *CancelError) StackTrace() *StackTrace {
+	return cancelError.Stack
+}
+
+// WithCancel is like context.WithCancel, but the cancel function records the stack of
+// its caller as the cause of the cancellation.
+func WithCancel(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	return ctx, func() {
+		cancelWithStack(ctx, cancel, context.Canceled)
+	}
+}
+
+// WithCancelCause is like context.WithCancelCause, but the cancel function wraps the
+// cause, or context.Canceled if nil, into a *CancelError holding the stack of its caller.
+func WithCancelCause(parent context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+	return ctx, func(cause error) {
+		if cause == nil {
+			cause = context.Canceled
+		}
+		cancelWithStack(ctx, cancel, cause)
+	}
+}
+
+// WithTimeout is like context.WithTimeout, but the cancel function records the stack of
+// its caller, and an expired deadline records the stack at which the timeout was set.
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return withDeadline(parent, time.Now().Add(timeout))
+}
+
+// WithDeadline is like context.WithDeadline, but the cancel function records the stack of
+// its caller, and an expired deadline records the stack at which the deadline was set.
+func WithDeadline(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	return withDeadline(parent, deadline)
+}
+
+// withDeadline implements WithTimeout and WithDeadline.
+func withDeadline(parent context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	expired := &CancelError{
+		Cause: context.DeadlineExceeded,
+		Stack: NewStackTrace(&Config{BufferSize: DefaultConfig.BufferSize, SkipFrames: 2}), // +2 to skip withDeadline and its caller
+	}
+	cancelCtx, cancel := context.WithCancelCause(parent)
+	ctx, stop := context.WithDeadlineCause(cancelCtx, deadline, expired)
+	return ctx, func() {
+		cancelWithStack(ctx, cancel, context.Canceled)
+		stop()
+	}
+}
+
+// cancelWithStack cancels the context with the cause wrapped with the stack of the caller
+// of the cancel function. Canceling an already canceled context does not capture a stack.
+func cancelWithStack(ctx context.Context, cancel context.CancelCauseFunc, cause error) {
+	if ctx.Err() != nil {
+		cancel(cause)
+		return
+	}
+	cancel(&CancelError{
+		Cause: cause,
+		Stack: NewStackTrace(&Config{BufferSize: DefaultConfig.BufferSize, SkipFrames: 2}), // +2 to skip cancelWithStack and the cancel function
+	})
+}
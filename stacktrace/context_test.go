@@ -0,0 +1,86 @@
+package stacktrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cancelFromHelper(cancel context.CancelFunc) {
+	cancel()
+}
+
+func TestWithCancel(t *testing.T) {
+	ctx, cancel := WithCancel(context.Background())
+	cancelFromHelper(cancel)
+	cancel()
+
+	cause := context.Cause(ctx)
+	var cancelError *CancelError
+	if !errors.As(cause, &cancelError) {
+		t.Fatalf("context.Cause = %v, want a *CancelError", cause)
+	}
+	if !errors.Is(cause, context.Canceled) || !errors.Is(ctx.Err(), context.Canceled) {
+		t.Errorf("cause %v is not context.Canceled", cause)
+	}
+	if frames := cancelError.Stack.Frames(); len(frames) == 0 || frames[0].Function != "stacktrace.cancelFromHelper" {
+		t.Errorf("canceller stack = %v, want cancelFromHelper first", frames)
+	}
+	if !strings.HasPrefix(cause.Error(), "context canceled (by stacktrace.cancelFromHelper at ") {
+		t.Errorf("CancelError.Error() = %q", cause.Error())
+	}
+}
+
+func TestWithCancelCause(t *testing.T) {
+	errShutdown := errors.New("shutting down")
+	ctx, cancel := WithCancelCause(context.Background())
+	cancel(errShutdown)
+
+	cause := context.Cause(ctx)
+	if !errors.Is(cause, errShutdown) {
+		t.Errorf("context.Cause = %v, want %v", cause, errShutdown)
+	}
+	var cancelError *CancelError
+	if !errors.As(cause, &cancelError) || !strings.Contains(cancelError.Stack.Frames().String(), "TestWithCancelCause") {
+		t.Errorf("cause does not hold the canceller stack: %v", cause)
+	}
+
+	ctx, cancel = WithCancelCause(context.Background())
+	cancel(nil)
+	if !errors.Is(context.Cause(ctx), context.Canceled) {
+		t.Errorf("context.Cause = %v, want context.Canceled for a nil cause", context.Cause(ctx))
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	cause := context.Cause(ctx)
+	var cancelError *CancelError
+	if !errors.As(cause, &cancelError) || !errors.Is(cause, context.DeadlineExceeded) {
+		t.Fatalf("context.Cause = %v, want a deadline *CancelError", cause)
+	}
+	if frames := cancelError.Stack.Frames(); len(frames) == 0 || frames[0].Function != "stacktrace.TestWithTimeout" {
+		t.Errorf("deadline stack = %v, want TestWithTimeout first", frames)
+	}
+
+	ctx, cancel = WithDeadline(context.Background(), time.Now().Add(time.Hour))
+	cancelFromHelper(cancel)
+	if !errors.As(context.Cause(ctx), &cancelError) || !errors.Is(cancelError, context.Canceled) {
+		t.Fatalf("context.Cause = %v, want a cancel *CancelError", context.Cause(ctx))
+	}
+	if frames := cancelError.Stack.Frames(); frames[0].Function != "stacktrace.cancelFromHelper" {
+		t.Errorf("canceller stack = %v, want cancelFromHelper first", frames)
+	}
+}
+
+func TestCancelErrorWithoutFrames(t *testing.T) {
+	cancelError := &CancelError{Cause: context.Canceled, Stack: &StackTrace{}}
+	if cancelError.Error() != "context canceled" {
+		t.Errorf("CancelError.Error() = %q, want %q", cancelError.Error(), "context canceled")
+	}
+}
@@ -0,0 +1,196 @@
+// Package crash writes self-contained crash reports for unrecovered panics.
+// A report holds the panic value, the structured stacks of all goroutines, build
+// information and an allowlist of environment variables, in JSON and as text.
+package crash
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+// Config holds the configuration for crash report generation.
+type Config struct {
+	// Dir is the directory crash reports are written to. The temporary directory is used if empty.
+	Dir string
+	// EnvAllowlist lists the environment variables included in crash reports.
+	EnvAllowlist []string
+	// Output receives a notice with the paths of written crash reports. Nothing is written if nil.
+	Output io.Writer
+}
+
+// DefaultConfig provides default configuration values.
+var DefaultConfig = Config{
+	Dir:          "",
+	EnvAllowlist: []string{"GOMAXPROCS", "GOGC", "GOMEMLIMIT", "GODEBUG", "GOTRACEBACK"},
+	Output:       os.Stderr,
+}
+
+// Report is a crash report of an unrecovered panic.
+type Report struct {
+	Time       time.Time              `json:"time"`
+	Panic      string                 `json:"panic"`
+	PanicType  string                 `json:"panic_type"`
+	PanicKind  string                 `json:"panic_kind"`
+	GoVersion  string                 `json:"go_version"`
+	GOOS       string                 `json:"goos"`
+	GOARCH     string                 `json:"goarch"`
+	Build      *Build                 `json:"build,omitempty"`
+	Env        map[string]string      `json:"env,omitempty"`
+	Goroutines []stacktrace.Goroutine `json:"goroutines"`
+}
+
+// Build is the build information embedded in the binary.
+type Build struct {
+	Path     string            `json:"path"`
+	Version  string            `json:"version"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// Guard writes a crash report if the calling goroutine panics, then re-panics with the same value.
+// It must be deferred directly, typically as the first statement of main:
+//
+//	defer crash.Guard()
+func Guard() {
+	if r := recover(); r != nil {
+		DefaultConfig.handle(r)
+		panic(r)
+	}
+}
+
+// Guard writes a crash report with the configuration if the calling goroutine panics,
+// then re-panics with the same value. It must be deferred directly.
+func (config *Config) Guard() {
+	if r := recover(); r != nil {
+		config.handle(r)
+		panic(r)
+	}
+}
+
+// handle writes the crash report of the recovered value and reports the outcome to Output.
+func (config *Config) handle(value any) {
+	jsonPath, textPath, err := NewReport(value, config).Write(config.Dir)
+	if config.Output == nil {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(config.Output, "crash: writing crash report: %v\n", err)
+		return
+	}
+	fmt.Fprintf(config.Output, "crash: report written to %s and %s\n", jsonPath, textPath)
+}
+
+// NewReport creates a crash report of the panic value with the stacks of all goroutines.
+func NewReport(value any, config *Config) *Report {
+	// Use default config if not provided
+	if config == nil {
+		config = &DefaultConfig
+	}
+
+	report := &Report{
+		Time:       time.Now().UTC(),
+		Panic:      fmt.Sprint(value),
+		PanicType:  fmt.Sprintf("%T", value),
+		PanicKind:  stacktrace.ClassifyPanic(value).String(),
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		Goroutines: stacktrace.Goroutines(),
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		report.Build = &Build{
+			Path:     info.Path,
+			Version:  info.Main.Version,
+			Settings: make(map[string]string, len(info.Settings)),
+		}
+		for _, setting := range info.Settings {
+			report.Build.Settings[setting.Key] = setting.Value
+		}
+	}
+
+	for _, name := range config.EnvAllowlist {
+		if value, ok := os.LookupEnv(name); ok {
+			if report.Env == nil {
+				report.Env = make(map[string]string)
+			}
+			report.Env[name] = value
+		}
+	}
+
+	return report
+}
+
+// Write writes the report as JSON and as text into dir, or the temporary directory if
+// dir is empty, and returns the paths of both files.
+func (report *Report) Write(dir string) (string, string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", "", err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("crash-%s-%d", report.Time.Format("20060102T150405.000000000Z"), os.Getpid()))
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+	jsonPath, textPath := base+".json", base+".txt"
+	if err := os.WriteFile(jsonPath, data, 0o600); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(textPath, []byte(report.String()), 0o600); err != nil {
+		return "", "", err
+	}
+	return jsonPath, textPath, nil
+}
+
+// String returns the human-readable rendering of the report.
+func (report *Report) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Crash report %s\n", report.Time.Format(time.RFC3339Nano))
+	fmt.Fprintf(&builder, "Panic: %s (%s)\n", report.Panic, report.PanicType)
+	fmt.Fprintf(&builder, "Kind: %s\n", report.PanicKind)
+	fmt.Fprintf(&builder, "Go: %s %s/%s\n", report.GoVersion, report.GOOS, report.GOARCH)
+	if report.Build != nil {
+		fmt.Fprintf(&builder, "Build: %s %s\n", report.Build.Path, report.Build.Version)
+		writeSorted(&builder, report.Build.Settings)
+	}
+	if len(report.Env) > 0 {
+		builder.WriteString("Environment:\n")
+		writeSorted(&builder, report.Env)
+	}
+	for _, goroutine := range report.Goroutines {
+		fmt.Fprintf(&builder, "\ngoroutine %d [%s]:\n", goroutine.ID, goroutine.State)
+		if len(goroutine.Frames) > 0 {
+			builder.WriteString(goroutine.Frames.String())
+			builder.WriteString("\n")
+		}
+		if goroutine.CreatedBy.Function != "" {
+			fmt.Fprintf(&builder, "created by %s:%d %s\n", goroutine.CreatedBy.File, goroutine.CreatedBy.Line, goroutine.CreatedBy.Function)
+		}
+	}
+	return builder.String()
+}
+
+// writeSorted writes the key-value pairs indented and sorted by key.
+func writeSorted(builder *strings.Builder, values map[string]string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(builder, "  %s=%s\n", key, values[key])
+	}
+}
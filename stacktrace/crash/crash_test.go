@@ -0,0 +1,117 @@
+package crash
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewReport(t *testing.T) {
+	t.Setenv("GOGC", "50")
+	t.Setenv("SECRET_TOKEN", "hunter2")
+
+	report := NewReport("boom", nil)
+	if report.Panic != "boom" || report.PanicType != "string" || report.PanicKind != "string" {
+		t.Errorf("report panic = %q (%s, %s), want boom (string, string)", report.Panic, report.PanicType, report.PanicKind)
+	}
+	if report.GoVersion == "" || report.GOOS == "" || report.GOARCH == "" {
+		t.Errorf("report is missing runtime information: %+v", report)
+	}
+	if report.Env["GOGC"] != "50" {
+		t.Errorf("report environment = %v, want GOGC", report.Env)
+	}
+	if _, ok := report.Env["SECRET_TOKEN"]; ok {
+		t.Error("report contains an environment variable outside the allowlist")
+	}
+	if !strings.Contains(report.String(), "TestNewReport") {
+		t.Error("report does not contain the stack of the current goroutine")
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	report := NewReport(os.ErrNotExist, &Config{})
+
+	jsonPath, textPath, err := report.Write(dir)
+	if err != nil {
+		t.Fatalf("Report.Write returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("reading JSON report: %v", err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("decoding JSON report: %v", err)
+	}
+	if decoded.Panic != "file does not exist" || len(decoded.Goroutines) == 0 || len(decoded.Goroutines[0].Frames) == 0 {
+		t.Errorf("decoded report is incomplete: %+v", decoded)
+	}
+
+	text, err := os.ReadFile(textPath)
+	if err != nil {
+		t.Fatalf("reading text report: %v", err)
+	}
+	if string(text) != report.String() {
+		t.Error("text report does not match Report.String()")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	var output bytes.Buffer
+	config := &Config{Dir: t.TempDir(), Output: &output}
+
+	var repanicked any
+	func() {
+		defer func() { repanicked = recover() }()
+		defer config.Guard()
+		panic("boom")
+	}()
+
+	if repanicked != "boom" {
+		t.Errorf("Guard re-panicked with %v, want boom", repanicked)
+	}
+	if !strings.HasPrefix(output.String(), "crash: report written to ") {
+		t.Errorf("Guard wrote %q", output.String())
+	}
+	reports, err := filepath.Glob(filepath.Join(config.Dir, "crash-*.txt"))
+	if err != nil || len(reports) != 1 {
+		t.Fatalf("found crash reports %v (%v), want 1", reports, err)
+	}
+	text, err := os.ReadFile(reports[0])
+	if err != nil {
+		t.Fatalf("reading text report: %v", err)
+	}
+	if !strings.Contains(string(text), "Panic: boom (string)") || !strings.Contains(string(text), "TestGuard") {
+		t.Errorf("text report is incomplete:\n%s", text)
+	}
+}
+
+func TestGuardWriteError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var output bytes.Buffer
+	config := &Config{Dir: filepath.Join(file, "reports"), Output: &output}
+
+	func() {
+		defer func() { _ = recover() }()
+		defer config.Guard()
+		panic("boom")
+	}()
+
+	if !strings.HasPrefix(output.String(), "crash: writing crash report: ") {
+		t.Errorf("Guard wrote %q", output.String())
+	}
+}
+
+func TestGuardWithoutPanic(t *testing.T) {
+	func() {
+		defer Guard()
+	}()
+}
@@ -0,0 +1,212 @@
+package stacktrace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// encodingMagic identifies a stream of binary encoded frames.
+	encodingMagic = "GKST"
+	// encodingVersion is the version of the binary encoding.
+	encodingVersion = 1
+	// maxEncodedFrames is the maximum number of frames accepted in a decoded stack.
+	maxEncodedFrames = 1 << 20
+	// maxPreallocatedFrames is the maximum number of frames allocated before they are decoded.
+	maxPreallocatedFrames = 64
+	// maxEncodedString is the maximum length accepted for a decoded string.
+	maxEncodedString = 1 << 20
+)
+
+var (
+	// ErrInvalidEncoding is returned when binary encoded frames cannot be decoded.
+	ErrInvalidEncoding = errors.New("stacktrace: invalid binary encoding")
+)
+
+// Encoder writes a stream of frames in a compact binary encoding. PCs are stored as
+// zigzag varint deltas and function and file names are interned in a string table
+// shared by all stacks of the stream, so each name is written only once.
+//
+// As with bufio.Writer, once a write fails, the stream is left incomplete and every
+// further Encode returns the error.
+type Encoder struct {
+	w       io.Writer
+	strings map[string]uint64
+	header  bool
+	buf     []byte
+	err     error // Error of the first failed write.
+}
+
+// NewEncoder creates an encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, strings: make(map[string]uint64)}
+}
+
+// Encode writes the frames as the next stack of the stream.
+func (encoder *Encoder) Encode(frames Frames) error {
+	if encoder.err != nil {
+		return encoder.err
+	}
+	buf := encoder.buf[:0]
+	if !encoder.header {
+		buf = append(buf, encodingMagic...)
+		buf = append(buf, encodingVersion)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(frames)))
+	var previousPC uintptr
+	for _, frame := range frames {
+		buf = binary.AppendVarint(buf, int64(frame.PC-previousPC))
+		previousPC = frame.PC
+		buf = encoder.appendString(buf, frame.Function)
+		buf = encoder.appendString(buf, frame.File)
+		buf = binary.AppendUvarint(buf, uint64(max(frame.Line, 0)))
+	}
+	encoder.buf = buf
+	if _, err := encoder.w.Write(buf); err != nil {
+		// The table holds the strings of the failed write, which the decoder never sees.
+		encoder.err = err
+		return err
+	}
+	encoder.header = true
+	return nil
+}
+
+// appendString appends the string table index of s, followed by s itself if it is new.
+func (encoder *Encoder) appendString(buf []byte, s string) []byte {
+	if index, ok := encoder.strings[s]; ok {
+		return binary.AppendUvarint(buf, index)
+	}
+	index := uint64(len(encoder.strings))
+	encoder.strings[s] = index
+	buf = binary.AppendUvarint(buf, index)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// Decoder reads a stream of frames written by an Encoder.
+type Decoder struct {
+	r       *bufio.Reader
+	strings []string
+	header  bool
+}
+
+// NewDecoder creates a decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next stack of the stream. It returns io.EOF when the stream ends.
+func (decoder *Decoder) Decode() (Frames, error) {
+	if !decoder.header {
+		header := make([]byte, len(encodingMagic)+1)
+		if _, err := io.ReadFull(decoder.r, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("%w: %w", ErrInvalidEncoding, err)
+		}
+		if string(header[:len(encodingMagic)]) != encodingMagic || header[len(encodingMagic)] != encodingVersion {
+			return nil, fmt.Errorf("%w: unsupported header %q", ErrInvalidEncoding, header)
+		}
+		decoder.header = true
+	}
+
+	count, err := binary.ReadUvarint(decoder.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidEncoding, err)
+	}
+	if count > maxEncodedFrames {
+		return nil, fmt.Errorf("%w: too many frames (%d)", ErrInvalidEncoding, count)
+	}
+
+	frames := make(Frames, 0, min(count, maxPreallocatedFrames)) // The count is untrusted until the frames are read.
+	var pc uintptr
+	for i := uint64(0); i < count; i++ {
+		delta, err := binary.ReadVarint(decoder.r)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		pc += uintptr(delta)
+		function, err := decoder.readString()
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		file, err := decoder.readString()
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		line, err := binary.ReadUvarint(decoder.r)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+		frames = append(frames, Frame{Function: function, File: file, Line: int(line), PC: pc})
+	}
+	return frames, nil
+}
+
+// readString reads a string table reference, adding the string to the table if it is new.
+func (decoder *Decoder) readString() (string, error) {
+	index, err := binary.ReadUvarint(decoder.r)
+	if err != nil {
+		return "", err
+	}
+	if index < uint64(len(decoder.strings)) {
+		return decoder.strings[index], nil
+	}
+	if index != uint64(len(decoder.strings)) {
+		return "", fmt.Errorf("string index %d out of range", index)
+	}
+	length, err := binary.ReadUvarint(decoder.r)
+	if err != nil {
+		return "", err
+	}
+	if length > maxEncodedString {
+		return "", fmt.Errorf("string too long (%d)", length)
+	}
+	buf, err := io.ReadAll(io.LimitReader(decoder.r, int64(length))) // The length is untrusted until the bytes are read.
+	if err != nil {
+		return "", err
+	}
+	if uint64(len(buf)) != length {
+		return "", io.ErrUnexpectedEOF
+	}
+	decoder.strings = append(decoder.strings, string(buf))
+	return decoder.strings[index], nil
+}
+
+// decodeError wraps an error occurring inside a stack, where the end of the stream is unexpected.
+func decodeError(err error) error {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidEncoding, err)
+}
+
+// MarshalBinary encodes the frames as a single-stack stream.
+func (frames Frames) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(frames); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes frames encoded by MarshalBinary.
+func (frames *Frames) UnmarshalBinary(data []byte) error {
+	decoded, err := NewDecoder(bytes.NewReader(data)).Decode()
+	if errors.Is(err, io.EOF) {
+		return decodeError(err)
+	}
+	if err != nil {
+		return err
+	}
+	*frames = decoded
+	return nil
+}
@@ -0,0 +1,184 @@
+package stacktrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestFramesMarshalBinary(t *testing.T) {
+	frames := NewStackTrace(&Config{BufferSize: 2048, SkipFrames: 0}).Frames()
+	data, err := frames.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Frames.MarshalBinary returned error: %v", err)
+	}
+
+	var decoded Frames
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Frames.UnmarshalBinary returned error: %v", err)
+	}
+	if !decoded.Equal(frames) {
+		t.Errorf("decoded frames = %v, want %v", decoded, frames)
+	}
+	for i := range frames {
+		if decoded[i].PC != frames[i].PC || frames[i].PC == 0 {
+			t.Errorf("frame %d PC = %#x, want %#x", i, decoded[i].PC, frames[i].PC)
+		}
+	}
+
+	jsonData, err := json.Marshal(frames)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	if len(data) >= len(jsonData) {
+		t.Errorf("binary encoding is %d bytes, JSON is %d bytes", len(data), len(jsonData))
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	stacks := []Frames{
+		{{Function: "main.leaf", File: "/src/main.go", Line: 10, PC: 0x4a1000}, {Function: "main.main", File: "/src/main.go", Line: 20, PC: 0x4a0f00}},
+		{},
+		{{Function: "main.main", File: "/src/main.go", Line: 21, PC: 0x4a0f10}},
+	}
+
+	var buf bytes.Buffer
+	encoder := NewEncoder(&buf)
+	for _, frames := range stacks {
+		if err := encoder.Encode(frames); err != nil {
+			t.Fatalf("Encoder.Encode returned error: %v", err)
+		}
+	}
+	first := buf.Len()
+	if err := encoder.Encode(stacks[2]); err != nil {
+		t.Fatalf("Encoder.Encode returned error: %v", err)
+	}
+	if size := buf.Len() - first; size > 8 {
+		t.Errorf("repeated stack encoded in %d bytes, want interned names", size)
+	}
+	stacks = append(stacks, stacks[2])
+
+	decoder := NewDecoder(&buf)
+	for i, expected := range stacks {
+		frames, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("Decoder.Decode returned error for stack %d: %v", i, err)
+		}
+		if !frames.Equal(expected) || len(frames) > 0 && frames[0].PC != expected[0].PC {
+			t.Errorf("stack %d = %+v, want %+v", i, frames, expected)
+		}
+	}
+	if _, err := decoder.Decode(); !errors.Is(err, io.EOF) {
+		t.Errorf("Decoder.Decode at end of stream returned %v, want io.EOF", err)
+	}
+}
+
+// failingWriter fails the write with the given number, counting from 1.
+type failingWriter struct {
+	buf    bytes.Buffer
+	writes int
+	fail   int
+}
+
+func (writer *failingWriter) Write(p []byte) (int, error) {
+	writer.writes++
+	if writer.writes == writer.fail {
+		return 0, errors.New("disk full")
+	}
+	return writer.buf.Write(p)
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	stacks := []Frames{
+		{{Function: "main.main", File: "/src/main.go", Line: 20, PC: 0x4a0f00}},
+		{{Function: "main.leaf", File: "/src/leaf.go", Line: 10, PC: 0x4a1000}},
+		{{Function: "main.leaf", File: "/src/leaf.go", Line: 11, PC: 0x4a1010}},
+	}
+	writer := &failingWriter{fail: 2}
+	encoder := NewEncoder(writer)
+	if err := encoder.Encode(stacks[0]); err != nil {
+		t.Fatalf("Encoder.Encode returned error: %v", err)
+	}
+	failed := encoder.Encode(stacks[1])
+	if failed == nil {
+		t.Fatal("Encoder.Encode returned no error for a failed write")
+	}
+	if err := encoder.Encode(stacks[2]); err != failed {
+		t.Errorf("Encoder.Encode after a failed write returned %v, want the first error %v", err, failed)
+	}
+	if writer.writes != 2 {
+		t.Errorf("Encoder wrote %d times, want no write after the failed one", writer.writes)
+	}
+
+	decoder := NewDecoder(&writer.buf)
+	if frames, err := decoder.Decode(); err != nil || !frames.Equal(stacks[0]) {
+		t.Errorf("Decoder.Decode() = %v, %v, want the stack written before the failure", frames, err)
+	}
+	if _, err := decoder.Decode(); !errors.Is(err, io.EOF) {
+		t.Errorf("Decoder.Decode after the written stacks returned %v, want io.EOF", err)
+	}
+}
+
+func TestDecoderInvalid(t *testing.T) {
+	valid, err := Frames{{Function: "main.main", File: "/src/main.go", Line: 3}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := map[string][]byte{
+		"Empty":         nil,
+		"BadMagic":      []byte("XXXX\x01\x00"),
+		"BadVersion":    []byte("GKST\x09\x00"),
+		"ShortHeader":   []byte("GK"),
+		"Truncated":     valid[:len(valid)-3],
+		"BadIndex":      []byte("GKST\x01\x01\x00\x05"),
+		"TooManyFrames": append([]byte("GKST\x01"), 0xff, 0xff, 0xff, 0xff, 0x0f),
+	}
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var frames Frames
+			if err := frames.UnmarshalBinary(data); !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("Frames.UnmarshalBinary returned %v, want ErrInvalidEncoding", err)
+			}
+		})
+	}
+}
+
+func TestDecoderUntrustedSizes(t *testing.T) {
+	testCases := map[string][]byte{
+		// 1<<20 frames announced, none present.
+		"FrameCount": append([]byte("GKST\x01"), 0x80, 0x80, 0x40),
+		// A string of 1<<20 bytes announced, none present.
+		"StringLength": append([]byte("GKST\x01\x01\x00\x00"), 0x80, 0x80, 0x40),
+	}
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			var frames Frames
+			err := frames.UnmarshalBinary(data)
+			runtime.ReadMemStats(&after)
+			if !errors.Is(err, ErrInvalidEncoding) {
+				t.Errorf("Frames.UnmarshalBinary returned %v, want ErrInvalidEncoding", err)
+			}
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<10 {
+				t.Errorf("decoding a %d byte stream allocated %d bytes", len(data), allocated)
+			}
+		})
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	frames := NewStackTrace(&Config{BufferSize: 2048, SkipFrames: 0}).Frames()
+	encoder := NewEncoder(io.Discard)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := encoder.Encode(frames); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package stacktrace
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// flameGraphWidth is the width of the rendered flamegraph in pixels.
+	flameGraphWidth = 1200
+	// flameGraphFrameHeight is the height of a single flamegraph frame in pixels.
+	flameGraphFrameHeight = 16
+	// flameGraphPadding is the padding around the flamegraph in pixels.
+	flameGraphPadding = 10
+	// flameGraphTitleHeight is the height reserved for the flamegraph title in pixels.
+	flameGraphTitleHeight = 30
+	// flameGraphCharWidth is the approximate width of a single character of frame text in pixels.
+	flameGraphCharWidth = 7
+)
+
+// Profile aggregates stack samples into Brendan Gregg's folded-stack format and flamegraphs.
+// It is safe for concurrent use.
+type Profile struct {
+	mu     sync.Mutex
+	counts map[string]int64 // Sample counts by folded stack, root first.
+}
+
+// NewProfile creates an empty profile.
+func NewProfile() *Profile {
+	return &Profile{counts: make(map[string]int64)}
+}
+
+// Add records count samples of the given frames, ordered innermost first as returned by StackTrace.Frames.
+func (profile *Profile) Add(frames Frames, count int64) {
+	if len(frames) == 0 || count <= 0 {
+		return
+	}
+	names := make([]string, len(frames))
+	for i, frame := range frames {
+		names[len(frames)-1-i] = foldedName(frame.Function)
+	}
+	key := strings.Join(names, ";")
+
+	profile.mu.Lock()
+	defer profile.mu.Unlock()
+	profile.counts[key] += count
+}
+
+// AddStackTrace records a single sample of the stack trace.
+func (profile *Profile) AddStackTrace(stackTrace *StackTrace) {
+	profile.Add(stackTrace.Frames(), 1)
+}
+
+// AddGoroutines records a single sample of every goroutine of the dump.
+func (profile *Profile) AddGoroutines(goroutines []Goroutine) {
+	for _, goroutine := range goroutines {
+		profile.Add(goroutine.Frames, 1)
+	}
+}
+
+// Sample adds a snapshot of all goroutines, except the sampling one, every interval
+// until ctx is done. It turns periodic goroutine dumps into a wall-clock profile.
+func (profile *Profile) Sample(ctx context.Context, interval time.Duration) {
+	self := currentGoroutineID()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, goroutine := range Goroutines() {
+				if goroutine.ID != self {
+					profile.Add(goroutine.Frames, 1)
+				}
+			}
+		}
+	}
+}
+
+// WriteFolded writes the profile in folded-stack format, one "root;...;leaf count" line per
+// distinct stack, sorted by stack.
+func (profile *Profile) WriteFolded(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	for _, key := range profile.keys() {
+		if _, err := fmt.Fprintf(writer, "%s %d\n", key, profile.count(key)); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// WriteSVG writes the profile as a self-contained SVG flamegraph.
+func (profile *Profile) WriteSVG(w io.Writer) error {
+	root := &flameNode{name: "all"}
+	for _, key := range profile.keys() {
+		root.insert(strings.Split(key, ";"), profile.count(key))
+	}
+
+	height := flameGraphTitleHeight + (root.depth()+1)*flameGraphFrameHeight + 2*flameGraphPadding
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "<?xml version=\"1.0\" standalone=\"no\"?>\n")
+	fmt.Fprintf(writer, "<svg version=\"1.1\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" xmlns=\"http://www.w3.org/2000/svg\">\n",
+		flameGraphWidth, height, flameGraphWidth, height)
+	fmt.Fprintf(writer, "<style>text{font-family:Verdana,sans-serif;font-size:12px;fill:#000}</style>\n")
+	fmt.Fprintf(writer, "<rect width=\"100%%\" height=\"100%%\" fill=\"#f8f8f8\"/>\n")
+	fmt.Fprintf(writer, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\" style=\"font-size:17px\">Flame Graph</text>\n",
+		flameGraphWidth/2, flameGraphPadding+17)
+	if root.value > 0 {
+		scale := float64(flameGraphWidth-2*flameGraphPadding) / float64(root.value)
+		root.render(writer, root.value, scale, flameGraphPadding, height-flameGraphPadding-flameGraphFrameHeight)
+	}
+	fmt.Fprintf(writer, "</svg>\n")
+	return writer.Flush()
+}
+
+// keys returns the folded stacks of the profile in sorted order.
+func (profile *Profile) keys() []string {
+	profile.mu.Lock()
+	defer profile.mu.Unlock()
+	keys := make([]string, 0, len(profile.counts))
+	for key := range profile.counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// count returns the number of samples of the folded stack.
+func (profile *Profile) count(key string) int64 {
+	profile.mu.Lock()
+	defer profile.mu.Unlock()
+	return profile.counts[key]
+}
+
+// foldedName replaces the characters that separate frames and counts in the folded format.
+func foldedName(function string) string {
+	return strings.NewReplacer(";", ":", " ", "_").Replace(function)
+}
+
+// flameNode is a node of the call tree rendered as a flamegraph.
+type flameNode struct {
+	name     string
+	value    int64
+	children []*flameNode
+}
+
+// insert adds count samples of the stack, ordered root first, below the node.
+func (node *flameNode) insert(stack []string, count int64) {
+	node.value += count
+	if len(stack) == 0 {
+		return
+	}
+	for _, child := range node.children {
+		if child.name == stack[0] {
+			child.insert(stack[1:], count)
+			return
+		}
+	}
+	child := &flameNode{name: stack[0]}
+	node.children = append(node.children, child)
+	child.insert(stack[1:], count)
+}
+
+// depth returns the number of levels below the node.
+func (node *flameNode) depth() int {
+	depth := 0
+	for _, child := range node.children {
+		if d := child.depth() + 1; d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// render writes the node at the given position and its children on top of it.
+func (node *flameNode) render(w io.Writer, total int64, scale, x float64, y int) {
+	width := float64(node.value) * scale
+	if width < 0.1 {
+		return
+	}
+	fmt.Fprintf(w, "<g><title>%s (%d samples, %.2f%%)</title>", html.EscapeString(node.name), node.value,
+		100*float64(node.value)/float64(total))
+	fmt.Fprintf(w, "<rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" rx=\"2\" fill=\"%s\"/>",
+		x, y, width, flameGraphFrameHeight-1, flameColor(node.name))
+	if chars := int(width/flameGraphCharWidth) - 1; chars >= 3 {
+		label := node.name
+		if len(label) > chars {
+			label = label[:chars-2] + ".."
+		}
+		fmt.Fprintf(w, "<text x=\"%.1f\" y=\"%d\">%s</text>", x+3, y+flameGraphFrameHeight-4, html.EscapeString(label))
+	}
+	fmt.Fprintf(w, "</g>\n")
+	for _, child := range node.children {
+		child.render(w, total, scale, x, y-flameGraphFrameHeight)
+		x += float64(child.value) * scale
+	}
+}
+
+// flameColor returns a warm color derived from the function name, stable across renders.
+func flameColor(name string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(name))
+	sum := hash.Sum32()
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+sum%50, 80+(sum>>8)%150, (sum>>16)%55)
+}
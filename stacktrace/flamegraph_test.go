@@ -0,0 +1,92 @@
+package stacktrace
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileWriteFolded(t *testing.T) {
+	profile := NewProfile()
+	profile.Add(Frames{{Function: "main.leaf"}, {Function: "main.main"}}, 2)
+	profile.Add(Frames{{Function: "main.other"}, {Function: "main.main"}}, 1)
+	profile.Add(Frames{{Function: "main.leaf"}, {Function: "main.main"}}, 3)
+	profile.Add(Frames{{Function: "pkg.(*T).with space;semi"}}, 1)
+	profile.Add(nil, 5)
+
+	var buf bytes.Buffer
+	if err := profile.WriteFolded(&buf); err != nil {
+		t.Fatalf("Profile.WriteFolded returned error: %v", err)
+	}
+	expected := "main.main;main.leaf 5\nmain.main;main.other 1\npkg.(*T).with_space:semi 1\n"
+	if buf.String() != expected {
+		t.Errorf("Profile.WriteFolded wrote %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestProfileWriteSVG(t *testing.T) {
+	profile := NewProfile()
+	profile.Add(Frames{{Function: "main.leaf<T>"}, {Function: "main.main"}}, 3)
+	profile.Add(Frames{{Function: "main.other"}, {Function: "main.main"}}, 1)
+
+	var buf bytes.Buffer
+	if err := profile.WriteSVG(&buf); err != nil {
+		t.Fatalf("Profile.WriteSVG returned error: %v", err)
+	}
+	svg := buf.String()
+
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("Profile.WriteSVG wrote invalid XML: %v", err)
+			}
+			break
+		}
+	}
+	for _, expected := range []string{"all (4 samples, 100.00%)", "main.main (4 samples", "main.leaf&lt;T&gt; (3 samples, 75.00%)"} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("Profile.WriteSVG output does not contain %q", expected)
+		}
+	}
+}
+
+func TestProfileAddStackTrace(t *testing.T) {
+	profile := NewProfile()
+	profile.AddStackTrace(NewStackTrace(&Config{BufferSize: 2048, SkipFrames: 0}))
+	profile.AddGoroutines(ParseGoroutines(testDump))
+
+	var buf bytes.Buffer
+	if err := profile.WriteFolded(&buf); err != nil {
+		t.Fatalf("Profile.WriteFolded returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "TestProfileAddStackTrace 1") {
+		t.Errorf("folded output does not contain the test frame as leaf: %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "main.main 1\n") {
+		t.Errorf("folded output does not contain the dump goroutine: %q", buf.String())
+	}
+}
+
+func TestProfileSample(t *testing.T) {
+	profile := NewProfile()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	profile.Sample(ctx, 5*time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := profile.WriteFolded(&buf); err != nil {
+		t.Fatalf("Profile.WriteFolded returned error: %v", err)
+	}
+	if strings.Contains(buf.String(), "Profile).Sample") {
+		t.Errorf("Profile.Sample recorded the sampling goroutine: %q", buf.String())
+	}
+	if buf.Len() == 0 {
+		t.Error("Profile.Sample recorded no samples")
+	}
+}
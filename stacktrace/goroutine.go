@@ -0,0 +1,175 @@
+package stacktrace
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// dumpBufferSize is the initial buffer size used to capture a goroutine dump.
+	dumpBufferSize = 64 << 10
+	// maxDumpBufferSize is the largest buffer used to capture a goroutine dump.
+	maxDumpBufferSize = 64 << 20
+)
+
+// Goroutine represents a single goroutine of a goroutine dump.
+type Goroutine struct {
+	ID        int64             `json:"id"`               // Goroutine ID.
+	State     string            `json:"state"`            // State such as "running" or "chan receive, 2 minutes".
+	Labels    map[string]string `json:"labels,omitempty"` // pprof labels, if the runtime includes them in the dump.
+	Frames    Frames            `json:"frames"`           // Filtered frames of the goroutine.
+	CreatedBy Frame             `json:"created_by"`       // Frame of the go statement that created the goroutine, if known.
+}
+
+// Goroutines captures and parses the stacks of all goroutines.
+func Goroutines() []Goroutine {
+	buf := make([]byte, dumpBufferSize)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxDumpBufferSize {
+			return ParseGoroutines(string(buf[:n]))
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// ParseGoroutines parses a goroutine dump in the format written by runtime.Stack
+// and by the runtime on an unrecovered panic.
+// Lines that do not belong to a goroutine block are ignored.
+func ParseGoroutines(dump string) []Goroutine {
+	var goroutines []Goroutine
+	var current *Goroutine
+	var frames Frames
+	createdBy := false
+
+	flush := func() {
+		if current != nil {
+			current.Frames = frames.filter()
+			goroutines = append(goroutines, *current)
+		}
+		current, frames, createdBy = nil, nil, false
+	}
+
+	for _, line := range strings.Split(dump, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if header, ok := parseGoroutineHeader(line); ok {
+			flush()
+			current = &header
+			continue
+		}
+		switch {
+		case current == nil || line == "":
+			continue
+		case strings.HasPrefix(line, "\t"):
+			file, lineNumber := parseFileLine(line)
+			if createdBy {
+				current.CreatedBy.File, current.CreatedBy.Line = file, lineNumber
+			} else if len(frames) > 0 {
+				frames[len(frames)-1].File, frames[len(frames)-1].Line = file, lineNumber
+			}
+		case strings.HasPrefix(line, "created by "):
+			createdBy = true
+			function := strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(function, " in goroutine "); i >= 0 {
+				function = function[:i]
+			}
+			current.CreatedBy.Function = NormalizeFunction(function)
+		case strings.HasPrefix(line, "..."):
+			continue
+		default:
+			frames = append(frames, Frame{Function: parseFunction(line)})
+		}
+	}
+	flush()
+	return goroutines
+}
+
+// parseGoroutineHeader parses a "goroutine N [state] {key: value}:" header line.
+// The labels are only present on runtimes that include them in tracebacks.
+func parseGoroutineHeader(line string) (Goroutine, bool) {
+	if !strings.HasPrefix(line, "goroutine ") || !strings.HasSuffix(line, ":") {
+		return Goroutine{}, false
+	}
+	start := strings.IndexByte(line, '[')
+	end := strings.IndexByte(line, ']')
+	if start < 0 || end < start {
+		return Goroutine{}, false
+	}
+	header := Goroutine{
+		ID:    parseGoroutineID(line),
+		State: line[start+1 : end],
+	}
+	if header.ID == 0 {
+		return Goroutine{}, false
+	}
+	if rest := strings.TrimSuffix(line[end+1:], ":"); strings.HasPrefix(rest, " {") && strings.HasSuffix(rest, "}") {
+		header.Labels = parseLabels(rest[2 : len(rest)-1])
+	}
+	return header, true
+}
+
+// parseLabels parses the "key: value, key: value" labels of a goroutine header.
+// Keys and values are quoted by the runtime when they contain special characters.
+func parseLabels(text string) map[string]string {
+	labels := make(map[string]string)
+	for text != "" {
+		key, rest, ok := parseLabelToken(text, ':')
+		if !ok || !strings.HasPrefix(rest, ": ") {
+			break
+		}
+		value, rest, ok := parseLabelToken(rest[2:], ',')
+		if !ok {
+			break
+		}
+		labels[key] = value
+		text = strings.TrimPrefix(rest, ", ")
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// parseLabelToken reads a quoted or bare label token terminated by the separator or the end of text.
+func parseLabelToken(text string, separator byte) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) {
+		quoted, err := strconv.QuotedPrefix(text)
+		if err != nil {
+			return "", "", false
+		}
+		token, err := strconv.Unquote(quoted)
+		return token, text[len(quoted):], err == nil
+	}
+	if i := strings.IndexByte(text, separator); i >= 0 {
+		return text[:i], text[i:], true
+	}
+	return text, "", true
+}
+
+// parseFunction extracts the function name from a dump line such as "main.(*T).run(0x1, ...)".
+func parseFunction(line string) string {
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndexByte(line, '('); i > 0 {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseFileLine extracts the file and line number from a dump line such as "\t/src/main.go:12 +0x1d".
+func parseFileLine(line string) (string, int) {
+	line = strings.TrimSpace(line)
+	if i := strings.LastIndex(line, " +0x"); i >= 0 {
+		line = line[:i]
+	}
+	i := strings.LastIndexByte(line, ':')
+	if i < 0 {
+		return line, 0
+	}
+	lineNumber, err := strconv.Atoi(line[i+1:])
+	if err != nil {
+		return line, 0
+	}
+	return line[:i], lineNumber
+}
@@ -0,0 +1,96 @@
+package stacktrace
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDump = `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:12 +0x1d
+
+goroutine 18 [chan receive, 2 minutes]:
+github.com/user/project/worker.(*Pool).run(0xc000010000, {0x4b2e20, 0xc000012000})
+	/src/app/worker/pool.go:40 +0x5a
+...additional frames elided...
+created by github.com/user/project/worker.Start in goroutine 1
+	/src/app/worker/start.go:21 +0x85
+`
+
+func TestParseGoroutines(t *testing.T) {
+	goroutines := ParseGoroutines(testDump)
+	if len(goroutines) != 2 {
+		t.Fatalf("ParseGoroutines returned %d goroutines, want 2", len(goroutines))
+	}
+
+	main := goroutines[0]
+	if main.ID != 1 || main.State != "running" {
+		t.Errorf("goroutine 1 parsed as ID %d state %q", main.ID, main.State)
+	}
+	expected := Frames{{Function: "main.main", File: "/src/app/main.go", Line: 12}}
+	if main.Frames.String() != expected.String() {
+		t.Errorf("goroutine 1 frames = %q, want %q", main.Frames, expected)
+	}
+
+	worker := goroutines[1]
+	if worker.ID != 18 || worker.State != "chan receive, 2 minutes" {
+		t.Errorf("goroutine 18 parsed as ID %d state %q", worker.ID, worker.State)
+	}
+	if len(worker.Frames) != 1 || worker.Frames[0].Function != "worker.(*Pool).run" || worker.Frames[0].Line != 40 {
+		t.Errorf("goroutine 18 frames = %v", worker.Frames)
+	}
+	createdBy := Frame{Function: "worker.Start", File: "/src/app/worker/start.go", Line: 21}
+	if worker.CreatedBy != createdBy {
+		t.Errorf("goroutine 18 CreatedBy = %v, want %v", worker.CreatedBy, createdBy)
+	}
+}
+
+func TestParseGoroutinesLabels(t *testing.T) {
+	dump := "goroutine 9 [select] {tenant: acme, \"request id\": \"r 1\"}:\nmain.serve()\n\t/src/app/main.go:30 +0x1d\n"
+	goroutines := ParseGoroutines(dump)
+	if len(goroutines) != 1 {
+		t.Fatalf("ParseGoroutines returned %d goroutines, want 1", len(goroutines))
+	}
+	goroutine := goroutines[0]
+	if goroutine.ID != 9 || goroutine.State != "select" {
+		t.Errorf("goroutine parsed as ID %d state %q", goroutine.ID, goroutine.State)
+	}
+	if goroutine.Labels["tenant"] != "acme" || goroutine.Labels["request id"] != "r 1" {
+		t.Errorf("goroutine labels = %v", goroutine.Labels)
+	}
+	if len(goroutine.Frames) != 1 || goroutine.Frames[0].Line != 30 {
+		t.Errorf("goroutine frames = %v", goroutine.Frames)
+	}
+}
+
+func TestGoroutines(t *testing.T) {
+	self := currentGoroutineID()
+	for _, goroutine := range Goroutines() {
+		if goroutine.ID == self {
+			if !strings.Contains(goroutine.Frames.String(), "TestGoroutines") {
+				t.Errorf("current goroutine frames do not contain TestGoroutines: %s", goroutine.Frames)
+			}
+			return
+		}
+	}
+	t.Error("Goroutines() does not contain the current goroutine")
+}
+
+func TestParseFileLine(t *testing.T) {
+	testCases := []struct {
+		input string
+		file  string
+		line  int
+	}{
+		{"\t/src/main.go:12 +0x1d", "/src/main.go", 12},
+		{"\tC:/src/main.go:7", "C:/src/main.go", 7},
+		{"\t/src/main.go", "/src/main.go", 0},
+	}
+
+	for _, tc := range testCases {
+		file, line := parseFileLine(tc.input)
+		if file != tc.file || line != tc.line {
+			t.Errorf("parseFileLine(%q) = %q, %d, want %q, %d", tc.input, file, line, tc.file, tc.line)
+		}
+	}
+}
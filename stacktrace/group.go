@@ -0,0 +1,105 @@
+package stacktrace
+
+import (
+	"context"
+	"sync"
+)
+
+// Group runs functions in goroutines and collects the first error, like errgroup.Group.
+// Goroutines are started with Go, so their stack traces carry the spawn site, and panics
+// are recovered into a *PanicError with the child's stack instead of crashing the process.
+// The zero value is a group without limit that does not cancel on error.
+type Group struct {
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	sem    chan struct{}
+	once   sync.Once
+	err    error
+}
+
+// NewGroup returns a group and a context derived from ctx. The context is canceled, with
+// the error as cause, when a function of the group first returns an error or panics, or
+// when Wait returns, whichever occurs first.
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit limits the number of goroutines of the group running at once. A negative
+// limit removes the limit. It must not be called while goroutines of the group are running.
+func (group *Group) SetLimit(limit int) {
+	if limit < 0 {
+		group.sem = nil
+		return
+	}
+	if len(group.sem) != 0 {
+		panic("stacktrace: Group.SetLimit called while goroutines are running")
+	}
+	group.sem = make(chan struct{}, limit)
+}
+
+// Go calls fn in a new goroutine, blocking until the limit of the group allows it.
+// The first error returned by a function of the group, or the *PanicError of the first
+// panic, is returned by Wait.
+func (group *Group) Go(fn func() error) {
+	if group.sem != nil {
+		group.sem <- struct{}{}
+	}
+	group.start(spawnChain(1), fn)
+}
+
+// TryGo calls fn in a new goroutine if the limit of the group allows it, and reports
+// whether it was started.
+func (group *Group) TryGo(fn func() error) bool {
+	if group.sem != nil {
+		select {
+		case group.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	group.start(spawnChain(1), fn)
+	return true
+}
+
+// Wait blocks until all the functions of the group have returned, then returns the first
+// error, if any.
+func (group *Group) Wait() error {
+	group.wg.Wait()
+	if group.cancel != nil {
+		group.cancel(group.err)
+	}
+	return group.err
+}
+
+// start runs fn in a goroutine with the creation chain, recording its error or panic.
+func (group *Group) start(chain []Frames, fn func() error) {
+	group.wg.Add(1)
+	go func() {
+		defer group.done()
+		id := currentGoroutineID()
+		ancestry.Store(id, chain)
+		defer ancestry.Delete(id)
+
+		var err error
+		if panicError := catch(func() { err = fn() }); panicError != nil {
+			err = panicError
+		}
+		if err != nil {
+			group.once.Do(func() {
+				group.err = err
+				if group.cancel != nil {
+					group.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+// done releases the slot of a finished goroutine.
+func (group *Group) done() {
+	if group.sem != nil {
+		<-group.sem
+	}
+	group.wg.Done()
+}
@@ -0,0 +1,98 @@
+package stacktrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func panickingTask() error {
+	panic("task failed")
+}
+
+func spawnPanickingTask(group *Group) {
+	group.Go(panickingTask)
+}
+
+func TestGroupError(t *testing.T) {
+	group, ctx := NewGroup(context.Background())
+	cause := errors.New("boom")
+	group.Go(func() error { return cause })
+	group.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := group.Wait(); err != cause {
+		t.Errorf("Group.Wait() = %v, want %v", err, cause)
+	}
+	if context.Cause(ctx) != cause {
+		t.Errorf("context cause = %v, want %v", context.Cause(ctx), cause)
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	group, ctx := NewGroup(context.Background())
+	spawnPanickingTask(group)
+
+	err := group.Wait()
+	var panicError *PanicError
+	if !errors.As(err, &panicError) {
+		t.Fatalf("Group.Wait() = %v, want a *PanicError", err)
+	}
+	if panicError.Value != "task failed" {
+		t.Errorf("PanicError.Value = %v, want %q", panicError.Value, "task failed")
+	}
+	if !strings.Contains(panicError.Stack.Frames().String(), "stacktrace.panickingTask") {
+		t.Errorf("panic stack does not contain the child frames:\n%s", panicError.Stack.Frames())
+	}
+	ancestors := panicError.Stack.Ancestors()
+	if len(ancestors) == 0 || !strings.Contains(ancestors[0].String(), "stacktrace.spawnPanickingTask") {
+		t.Errorf("panic stack does not contain the spawn site: %v", ancestors)
+	}
+	if !errors.Is(context.Cause(ctx), err) {
+		t.Errorf("context cause = %v, want the panic error", context.Cause(ctx))
+	}
+}
+
+func TestGroupLimit(t *testing.T) {
+	var group Group
+	group.SetLimit(2)
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		group.Go(func() error {
+			peak.Store(max(peak.Load(), running.Add(1)))
+			<-release
+			running.Add(-1)
+			return nil
+		})
+	}
+	if group.TryGo(func() error { return nil }) {
+		t.Error("Group.TryGo() started a goroutine beyond the limit")
+	}
+	close(release)
+	for i := 0; i < 8; i++ {
+		group.Go(func() error {
+			if n := running.Add(1); n > 2 {
+				peak.Store(n)
+			}
+			running.Add(-1)
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		t.Errorf("Group.Wait() = %v, want nil", err)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("%d goroutines ran at once, want at most 2", peak.Load())
+	}
+	if !group.TryGo(func() error { return nil }) {
+		t.Error("Group.TryGo() did not start a goroutine below the limit")
+	}
+	_ = group.Wait()
+}
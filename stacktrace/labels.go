@@ -0,0 +1,43 @@
+package stacktrace
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// NewStackTraceContext creates a new stack trace like NewStackTrace and attaches the pprof
+// labels carried by ctx, as set with pprof.Do or pprof.WithLabels. Labels of ctx take
+// precedence over labels the runtime reports for the goroutine.
+func NewStackTraceContext(ctx context.Context, config *Config) *StackTrace {
+	// Use default config if not provided
+	if config == nil {
+		config = &DefaultConfig
+	}
+	// +1 to skip NewStackTraceContext
+	stackTrace := NewStackTrace(&Config{BufferSize: config.BufferSize, SkipFrames: config.SkipFrames + 1})
+
+	labels := make(map[string]string, len(stackTrace.labels))
+	for key, value := range stackTrace.labels {
+		labels[key] = value
+	}
+	pprof.ForLabels(ctx, func(key, value string) bool {
+		labels[key] = value
+		return true
+	})
+	if len(labels) > 0 {
+		stackTrace.labels = labels
+	}
+	return stackTrace
+}
+
+// GoroutineID returns the ID of the goroutine that captured the stack trace, or 0 if unknown.
+func (stackTrace *StackTrace) GoroutineID() int64 {
+	return stackTrace.goroutineID
+}
+
+// Labels returns the pprof labels of the goroutine that captured the stack trace.
+// Labels are reported by the runtime on recent Go versions or taken from the context
+// passed to NewStackTraceContext.
+func (stackTrace *StackTrace) Labels() map[string]string {
+	return stackTrace.labels
+}
@@ -0,0 +1,62 @@
+package stacktrace
+
+import (
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+)
+
+func TestNewStackTraceContext(t *testing.T) {
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("request_id", "r-42", "tenant", "acme"))
+	st := NewStackTraceContext(ctx, &Config{BufferSize: 2048, SkipFrames: 0})
+
+	if st.Labels()["request_id"] != "r-42" || st.Labels()["tenant"] != "acme" {
+		t.Errorf("StackTrace.Labels() = %v, want request_id and tenant labels", st.Labels())
+	}
+	if st.GoroutineID() != currentGoroutineID() {
+		t.Errorf("StackTrace.GoroutineID() = %d, want %d", st.GoroutineID(), currentGoroutineID())
+	}
+	if len(st.Frames()) == 0 || !strings.Contains(st.Frames()[0].Function, "TestNewStackTraceContext") {
+		t.Errorf("first frame is not the caller of NewStackTraceContext: %v", st.Frames())
+	}
+	if limited := st.Limit(1); limited.GoroutineID() != st.GoroutineID() || len(limited.Labels()) != 2 {
+		t.Error("StackTrace.Limit() dropped the goroutine metadata")
+	}
+}
+
+func TestNewStackTraceContextWithoutLabels(t *testing.T) {
+	st := NewStackTraceContext(context.Background(), nil)
+	if len(st.Labels()) != 0 {
+		t.Errorf("StackTrace.Labels() = %v, want none", st.Labels())
+	}
+	if NewStackTrace(&Config{BufferSize: 0}).GoroutineID() != 0 {
+		t.Error("StackTrace.GoroutineID() is not 0 without raw stack trace")
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected map[string]string
+	}{
+		{`tenant: acme`, map[string]string{"tenant": "acme"}},
+		{`a: 1, b: 2`, map[string]string{"a": "1", "b": "2"}},
+		{`"key, with: comma": "value\n", plain: x`, map[string]string{"key, with: comma": "value\n", "plain": "x"}},
+		{`"broken`, nil},
+		{``, nil},
+	}
+
+	for _, tc := range testCases {
+		labels := parseLabels(tc.input)
+		if len(labels) != len(tc.expected) {
+			t.Errorf("parseLabels(%q) = %v, want %v", tc.input, labels, tc.expected)
+			continue
+		}
+		for key, value := range tc.expected {
+			if labels[key] != value {
+				t.Errorf("parseLabels(%q)[%q] = %q, want %q", tc.input, key, labels[key], value)
+			}
+		}
+	}
+}
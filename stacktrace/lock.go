@@ -0,0 +1,370 @@
+package stacktrace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockReportKind is the kind of problem described by a LockReport.
+type LockReportKind int
+
+const (
+	// LockWait reports a goroutine waiting for a lock longer than the threshold.
+	LockWait LockReportKind = iota + 1
+	// LockInversion reports two locks acquired in both orders, which can deadlock.
+	LockInversion
+)
+
+// LockConfig holds the configuration of Mutex and RWMutex.
+type LockConfig struct {
+	// WaitThreshold is the wait duration after which a LockWait report is emitted. Waits
+	// are not reported if it is not positive.
+	WaitThreshold time.Duration
+	// Report receives the lock reports. It is called without holding any lock. If nil,
+	// the reports go to DefaultLockConfig.Report.
+	Report func(report LockReport)
+}
+
+// DefaultLockConfig provides default configuration values, reporting to the standard error.
+// It is used by the locks without a configuration.
+var DefaultLockConfig = LockConfig{
+	WaitThreshold: time.Second,
+	Report:        func(report LockReport) { writeLockReport(os.Stderr, report) },
+}
+
+// LockReport describes a long lock wait or a lock-order inversion.
+type LockReport struct {
+	Kind LockReportKind
+	// Wait is the time the acquirer had been waiting when a LockWait was reported.
+	Wait time.Duration
+	// Acquirer is the stack of the goroutine acquiring the lock.
+	Acquirer *StackTrace
+	// Holders are the acquisition stacks of the current holders for a LockWait, or the
+	// stack at which the opposite order was first observed for a LockInversion.
+	Holders []*StackTrace
+}
+
+// String returns the human-readable rendering of the report.
+func (report LockReport) String() string {
+	var builder strings.Builder
+	switch report.Kind {
+	case LockWait:
+		fmt.Fprintf(&builder, "lock wait exceeded %s\ngoroutine %d waiting at:\n%s", report.Wait,
+			report.Acquirer.GoroutineID(), report.Acquirer.Frames().CollapsedString(DefaultCollapseRepeats))
+		for _, holder := range report.Holders {
+			fmt.Fprintf(&builder, "\nheld by goroutine %d acquired at:\n%s", holder.GoroutineID(),
+				holder.Frames().CollapsedString(DefaultCollapseRepeats))
+		}
+	case LockInversion:
+		fmt.Fprintf(&builder, "lock order inversion\ngoroutine %d acquiring at:\n%s",
+			report.Acquirer.GoroutineID(), report.Acquirer.Frames().CollapsedString(DefaultCollapseRepeats))
+		for _, holder := range report.Holders {
+			fmt.Fprintf(&builder, "\nopposite order observed in goroutine %d at:\n%s", holder.GoroutineID(),
+				holder.Frames().CollapsedString(DefaultCollapseRepeats))
+		}
+	}
+	return builder.String()
+}
+
+// writeLockReport writes the report followed by a blank line.
+func writeLockReport(w io.Writer, report LockReport) {
+	fmt.Fprintf(w, "%s\n\n", report)
+}
+
+// maxLockOrderEdges bounds the number of lock orders remembered, so that programs creating
+// locks continuously do not grow the lock graph without bound. The oldest orders are
+// forgotten first.
+const maxLockOrderEdges = 4096
+
+var (
+	// lockIDs generates the identities of tracked locks.
+	lockIDs atomic.Uint64
+	// lockGraphMu guards heldLocks, lockOrder and lockOrderEdges.
+	lockGraphMu sync.Mutex
+	// heldLocks lists the IDs of the locks held by each goroutine, in acquisition order.
+	heldLocks = make(map[int64][]uint64)
+	// lockOrder records where a lock was first acquired while holding another.
+	lockOrder = make(map[[2]uint64]*lockEdge)
+	// lockOrderEdges lists the keys of lockOrder, oldest first.
+	lockOrderEdges [][2]uint64
+)
+
+// lockEdge records the first acquisition of a lock while holding another.
+type lockEdge struct {
+	frames      Frames // Frames of the acquirer, without the raw stack trace.
+	goroutineID int64
+	reported    bool // Whether the edge was reported as part of an inversion.
+}
+
+// stackTrace returns the acquisition recorded by the edge as a stack trace.
+func (edge *lockEdge) stackTrace() *StackTrace {
+	return &StackTrace{frames: edge.frames, goroutineID: edge.goroutineID}
+}
+
+// addLockEdge records an edge of the lock graph, forgetting the oldest edge once the
+// graph holds maxLockOrderEdges edges. lockGraphMu must be held.
+func addLockEdge(key [2]uint64, edge *lockEdge) {
+	if len(lockOrderEdges) >= maxLockOrderEdges {
+		delete(lockOrder, lockOrderEdges[0])
+		lockOrderEdges = lockOrderEdges[1:]
+	}
+	lockOrder[key] = edge
+	lockOrderEdges = append(lockOrderEdges, key)
+}
+
+// lockState tracks the holders and the ordering of a single lock.
+type lockState struct {
+	once    sync.Once
+	id      uint64
+	mu      sync.Mutex
+	holders map[int64][]*StackTrace // Acquisition stacks by goroutine ID, in acquisition order.
+}
+
+// init assigns the lock identity on first use, keeping the zero value ready to use.
+func (state *lockState) init() {
+	state.once.Do(func() {
+		state.id = lockIDs.Add(1)
+		state.holders = make(map[int64][]*StackTrace)
+	})
+}
+
+// capture returns the stack of the caller of a lock method, skipping the lock internals.
+func (state *lockState) capture() *StackTrace {
+	state.init()
+	return NewStackTrace(&Config{BufferSize: DefaultConfig.BufferSize, SkipFrames: 2}) // +2 to skip capture and the lock method
+}
+
+// lockConfig returns the configuration of a lock, the default one if nil.
+func lockConfig(config *LockConfig) *LockConfig {
+	if config == nil {
+		return &DefaultLockConfig
+	}
+	return config
+}
+
+// report passes the report to Report, DefaultLockConfig.Report if nil, or writes it to the
+// standard error if both are nil.
+func (config *LockConfig) report(report LockReport) {
+	switch {
+	case config.Report != nil:
+		config.Report(report)
+	case DefaultLockConfig.Report != nil:
+		DefaultLockConfig.Report(report)
+	default:
+		writeLockReport(os.Stderr, report)
+	}
+}
+
+// checkOrder records the order of the lock relative to the locks held by the acquirer
+// and reports an inversion if the opposite order was observed before.
+func (state *lockState) checkOrder(config *LockConfig, acquirer *StackTrace) {
+	var reports []LockReport
+	lockGraphMu.Lock()
+	for _, held := range heldLocks[acquirer.GoroutineID()] {
+		if held == state.id {
+			continue
+		}
+		edge, ok := lockOrder[[2]uint64{held, state.id}]
+		if !ok {
+			edge = &lockEdge{frames: acquirer.Frames(), goroutineID: acquirer.GoroutineID()}
+			addLockEdge([2]uint64{held, state.id}, edge)
+		}
+		if opposite, ok := lockOrder[[2]uint64{state.id, held}]; ok && !edge.reported && !opposite.reported {
+			edge.reported, opposite.reported = true, true
+			reports = append(reports, LockReport{Kind: LockInversion, Acquirer: acquirer, Holders: []*StackTrace{opposite.stackTrace()}})
+		}
+	}
+	lockGraphMu.Unlock()
+
+	for _, report := range reports {
+		config.report(report)
+	}
+}
+
+// wait calls lock and emits a LockWait report if it blocks longer than the threshold.
+func (state *lockState) wait(config *LockConfig, acquirer *StackTrace, lock func()) {
+	threshold := config.WaitThreshold
+	if threshold <= 0 {
+		lock()
+		return
+	}
+	timer := time.AfterFunc(threshold, func() {
+		config.report(LockReport{Kind: LockWait, Wait: threshold, Acquirer: acquirer, Holders: state.snapshot()})
+	})
+	lock()
+	timer.Stop()
+}
+
+// snapshot returns the acquisition stacks of the current holders, ordered by goroutine ID.
+func (state *lockState) snapshot() []*StackTrace {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	holders := make([]*StackTrace, 0, len(state.holders))
+	for _, acquisitions := range state.holders {
+		holders = append(holders, acquisitions...)
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i].GoroutineID() < holders[j].GoroutineID() })
+	return holders
+}
+
+// acquired records the acquirer as a holder of the lock.
+func (state *lockState) acquired(acquirer *StackTrace) {
+	state.mu.Lock()
+	state.holders[acquirer.GoroutineID()] = append(state.holders[acquirer.GoroutineID()], acquirer)
+	state.mu.Unlock()
+
+	lockGraphMu.Lock()
+	heldLocks[acquirer.GoroutineID()] = append(heldLocks[acquirer.GoroutineID()], state.id)
+	lockGraphMu.Unlock()
+}
+
+// released forgets a holder of the lock: the calling goroutine if it holds the lock,
+// otherwise any holder, since locks may be released by another goroutine.
+func (state *lockState) released() {
+	state.init()
+	goroutineID := currentGoroutineID()
+	state.mu.Lock()
+	if _, ok := state.holders[goroutineID]; !ok {
+		for holderID := range state.holders {
+			goroutineID = holderID
+			break
+		}
+	}
+	if acquisitions := state.holders[goroutineID]; len(acquisitions) > 1 {
+		state.holders[goroutineID] = acquisitions[:len(acquisitions)-1]
+	} else {
+		delete(state.holders, goroutineID)
+	}
+	state.mu.Unlock()
+
+	lockGraphMu.Lock()
+	defer lockGraphMu.Unlock()
+	held := heldLocks[goroutineID]
+	for i := len(held) - 1; i >= 0; i-- {
+		if held[i] == state.id {
+			held = append(held[:i], held[i+1:]...)
+			break
+		}
+	}
+	if len(held) == 0 {
+		delete(heldLocks, goroutineID)
+	} else {
+		heldLocks[goroutineID] = held
+	}
+}
+
+// Mutex is a drop-in replacement for sync.Mutex that records the holder's stack and
+// goroutine ID, reports waits longer than the configured WaitThreshold with the
+// waiter's and holder's stacks, and reports lock-order inversions between tracked locks.
+// It is meant for debugging and tests: every acquisition captures a stack trace.
+// The zero value is an unlocked mutex using DefaultLockConfig.
+type Mutex struct {
+	// Config is the configuration of the mutex, DefaultLockConfig if nil.
+	// It must not be changed after the mutex is first used.
+	Config *LockConfig
+
+	mu    sync.Mutex
+	state lockState
+}
+
+// Lock locks the mutex.
+func (mutex *Mutex) Lock() {
+	acquirer := mutex.state.capture()
+	config := lockConfig(mutex.Config)
+	mutex.state.checkOrder(config, acquirer)
+	if !mutex.mu.TryLock() {
+		mutex.state.wait(config, acquirer, mutex.mu.Lock)
+	}
+	mutex.state.acquired(acquirer)
+}
+
+// TryLock tries to lock the mutex and reports whether it succeeded.
+func (mutex *Mutex) TryLock() bool {
+	acquirer := mutex.state.capture()
+	if !mutex.mu.TryLock() {
+		return false
+	}
+	mutex.state.checkOrder(lockConfig(mutex.Config), acquirer)
+	mutex.state.acquired(acquirer)
+	return true
+}
+
+// Unlock unlocks the mutex.
+func (mutex *Mutex) Unlock() {
+	mutex.state.released()
+	mutex.mu.Unlock()
+}
+
+// RWMutex is a drop-in replacement for sync.RWMutex with the same debugging as Mutex.
+// Readers are tracked as holders like writers.
+// The zero value is an unlocked mutex using DefaultLockConfig.
+type RWMutex struct {
+	// Config is the configuration of the mutex, DefaultLockConfig if nil.
+	// It must not be changed after the mutex is first used.
+	Config *LockConfig
+
+	mu    sync.RWMutex
+	state lockState
+}
+
+// Lock locks the mutex for writing.
+func (mutex *RWMutex) Lock() {
+	acquirer := mutex.state.capture()
+	config := lockConfig(mutex.Config)
+	mutex.state.checkOrder(config, acquirer)
+	if !mutex.mu.TryLock() {
+		mutex.state.wait(config, acquirer, mutex.mu.Lock)
+	}
+	mutex.state.acquired(acquirer)
+}
+
+// TryLock tries to lock the mutex for writing and reports whether it succeeded.
+func (mutex *RWMutex) TryLock() bool {
+	acquirer := mutex.state.capture()
+	if !mutex.mu.TryLock() {
+		return false
+	}
+	mutex.state.checkOrder(lockConfig(mutex.Config), acquirer)
+	mutex.state.acquired(acquirer)
+	return true
+}
+
+// Unlock unlocks the mutex for writing.
+func (mutex *RWMutex) Unlock() {
+	mutex.state.released()
+	mutex.mu.Unlock()
+}
+
+// RLock locks the mutex for reading.
+func (mutex *RWMutex) RLock() {
+	acquirer := mutex.state.capture()
+	config := lockConfig(mutex.Config)
+	mutex.state.checkOrder(config, acquirer)
+	if !mutex.mu.TryRLock() {
+		mutex.state.wait(config, acquirer, mutex.mu.RLock)
+	}
+	mutex.state.acquired(acquirer)
+}
+
+// TryRLock tries to lock the mutex for reading and reports whether it succeeded.
+func (mutex *RWMutex) TryRLock() bool {
+	acquirer := mutex.state.capture()
+	if !mutex.mu.TryRLock() {
+		return false
+	}
+	mutex.state.checkOrder(lockConfig(mutex.Config), acquirer)
+	mutex.state.acquired(acquirer)
+	return true
+}
+
+// RUnlock undoes a single RLock call.
+func (mutex *RWMutex) RUnlock() {
+	mutex.state.released()
+	mutex.mu.RUnlock()
+}
@@ -0,0 +1,264 @@
+package stacktrace
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordLockReports returns a lock configuration recording the reports, and a function
+// returning the recorded reports.
+func recordLockReports(threshold time.Duration) (*LockConfig, func() []LockReport) {
+	var mu sync.Mutex
+	var reports []LockReport
+	config := &LockConfig{
+		WaitThreshold: threshold,
+		Report: func(report LockReport) {
+			mu.Lock()
+			defer mu.Unlock()
+			reports = append(reports, report)
+		},
+	}
+	return config, func() []LockReport {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]LockReport(nil), reports...)
+	}
+}
+
+func holdLock(mutex *Mutex, locked chan<- struct{}, release <-chan struct{}) {
+	mutex.Lock()
+	close(locked)
+	<-release
+	mutex.Unlock()
+}
+
+func TestMutexWait(t *testing.T) {
+	config, reports := recordLockReports(10 * time.Millisecond)
+
+	mutex := Mutex{Config: config}
+	locked, release := make(chan struct{}), make(chan struct{})
+	go holdLock(&mutex, locked, release)
+	<-locked
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	mutex.Lock()
+	mutex.Unlock()
+
+	recorded := reports()
+	if len(recorded) != 1 || recorded[0].Kind != LockWait {
+		t.Fatalf("recorded reports %v, want a single LockWait", recorded)
+	}
+	report := recorded[0]
+	if report.Wait != 10*time.Millisecond || report.Acquirer.GoroutineID() != currentGoroutineID() {
+		t.Errorf("unexpected wait report: %+v", report)
+	}
+	if !strings.Contains(report.Acquirer.Frames().String(), "TestMutexWait") {
+		t.Errorf("waiter stack does not contain TestMutexWait: %s", report.Acquirer.Frames())
+	}
+	if len(report.Holders) != 1 || report.Holders[0].Frames()[0].Function != "stacktrace.holdLock" {
+		t.Errorf("holder stacks = %v, want holdLock", report.Holders)
+	}
+	if rendered := report.String(); !strings.HasPrefix(rendered, "lock wait exceeded 10ms\n") || !strings.Contains(rendered, "\nheld by goroutine ") {
+		t.Errorf("LockReport.String() = %q", rendered)
+	}
+}
+
+func TestMutexInversion(t *testing.T) {
+	config, reports := recordLockReports(time.Minute)
+
+	a, b := Mutex{Config: config}, Mutex{Config: config}
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	if len(reports()) != 0 {
+		t.Fatalf("recorded reports %v for a consistent order", reports())
+	}
+
+	for i := 0; i < 2; i++ {
+		b.Lock()
+		a.Lock()
+		a.Unlock()
+		b.Unlock()
+	}
+
+	recorded := reports()
+	if len(recorded) != 1 || recorded[0].Kind != LockInversion {
+		t.Fatalf("recorded reports %v, want a single LockInversion", recorded)
+	}
+	if len(recorded[0].Holders) != 1 || !strings.Contains(recorded[0].String(), "\nopposite order observed in goroutine ") {
+		t.Errorf("LockReport.String() = %q", recorded[0].String())
+	}
+}
+
+func TestMutexTryLock(t *testing.T) {
+	config, _ := recordLockReports(time.Minute)
+
+	mutex := Mutex{Config: config}
+	if !mutex.TryLock() {
+		t.Fatal("Mutex.TryLock failed on an unlocked mutex")
+	}
+	if mutex.TryLock() {
+		t.Error("Mutex.TryLock succeeded on a locked mutex")
+	}
+	if holders := mutex.state.snapshot(); len(holders) != 1 || !strings.Contains(holders[0].Frames().String(), "TestMutexTryLock") {
+		t.Errorf("holders = %v, want TestMutexTryLock", holders)
+	}
+	mutex.Unlock()
+	if holders := mutex.state.snapshot(); len(holders) != 0 {
+		t.Errorf("holders after Unlock = %v, want none", holders)
+	}
+}
+
+func TestRWMutex(t *testing.T) {
+	config, reports := recordLockReports(10 * time.Millisecond)
+
+	mutex := RWMutex{Config: config}
+	mutex.RLock()
+	if !mutex.TryRLock() {
+		t.Error("RWMutex.TryRLock failed while only read-locked")
+	}
+	mutex.RUnlock()
+	if mutex.TryLock() {
+		t.Error("RWMutex.TryLock succeeded while read-locked")
+	}
+	time.AfterFunc(50*time.Millisecond, mutex.RUnlock)
+	mutex.Lock()
+	if mutex.TryRLock() {
+		t.Error("RWMutex.TryRLock succeeded while write-locked")
+	}
+	mutex.Unlock()
+
+	recorded := reports()
+	if len(recorded) != 1 || recorded[0].Kind != LockWait || len(recorded[0].Holders) != 1 {
+		t.Fatalf("recorded reports %v, want a single LockWait with the reader as holder", recorded)
+	}
+	if !mutex.TryLock() {
+		t.Error("RWMutex.TryLock failed on an unlocked mutex")
+	}
+	mutex.Unlock()
+}
+
+func TestMutexConcurrent(t *testing.T) {
+	config, _ := recordLockReports(time.Minute)
+
+	mutex := Mutex{Config: config}
+	var wg sync.WaitGroup
+	counter := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				mutex.Lock()
+				counter++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if counter != 400 {
+		t.Errorf("counter = %d, want 400", counter)
+	}
+	lockGraphMu.Lock()
+	defer lockGraphMu.Unlock()
+	for goroutineID, held := range heldLocks {
+		for _, id := range held {
+			if id == mutex.state.id {
+				t.Errorf("goroutine %d still holds the mutex", goroutineID)
+			}
+		}
+	}
+}
+
+func TestLockOrderBounded(t *testing.T) {
+	config, reports := recordLockReports(time.Minute)
+
+	outer := Mutex{Config: config}
+	outer.Lock()
+	for i := 0; i < maxLockOrderEdges+10; i++ {
+		inner := Mutex{Config: config}
+		inner.Lock()
+		inner.Unlock()
+	}
+	outer.Unlock()
+	if len(reports()) != 0 {
+		t.Errorf("recorded reports %v for a consistent order", reports())
+	}
+
+	lockGraphMu.Lock()
+	defer lockGraphMu.Unlock()
+	if len(lockOrder) > maxLockOrderEdges || len(lockOrderEdges) != len(lockOrder) {
+		t.Errorf("lock graph holds %d edges (%d keys), want at most %d", len(lockOrder), len(lockOrderEdges), maxLockOrderEdges)
+	}
+	for key, edge := range lockOrder {
+		if key[0] == outer.state.id && len(edge.frames) == 0 {
+			t.Errorf("edge %v has no frames", key)
+		}
+	}
+}
+
+func TestMutexDefaultConfig(t *testing.T) {
+	config, reports := recordLockReports(time.Minute)
+	if lockConfig(nil) != &DefaultLockConfig || lockConfig(config) != config {
+		t.Error("lockConfig does not default to DefaultLockConfig")
+	}
+
+	var a Mutex
+	b := Mutex{Config: config}
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	if recorded := reports(); len(recorded) != 1 || recorded[0].Kind != LockInversion {
+		t.Errorf("recorded reports %v, want the inversion reported to the configuration of the acquired lock", recorded)
+	}
+}
+
+func TestMutexNilReport(t *testing.T) {
+	defaultConfig, reports := recordLockReports(time.Minute)
+	previous := DefaultLockConfig.Report
+	DefaultLockConfig.Report = defaultConfig.Report
+	defer func() { DefaultLockConfig.Report = previous }()
+
+	config := &LockConfig{WaitThreshold: 10 * time.Millisecond}
+	a, b := Mutex{Config: config}, Mutex{Config: config}
+	locked, release := make(chan struct{}), make(chan struct{})
+	go holdLock(&a, locked, release)
+	<-locked
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	b.Lock()
+	a.Lock()
+	a.Unlock()
+	b.Unlock()
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+
+	recorded := reports()
+	if len(recorded) != 2 || recorded[0].Kind != LockWait || recorded[1].Kind != LockInversion {
+		t.Errorf("recorded reports %v, want a LockWait and a LockInversion sent to DefaultLockConfig.Report", recorded)
+	}
+}
+
+func TestMutexWithoutThreshold(t *testing.T) {
+	config, reports := recordLockReports(0)
+
+	mutex := Mutex{Config: config}
+	locked, release := make(chan struct{}), make(chan struct{})
+	go holdLock(&mutex, locked, release)
+	<-locked
+	time.AfterFunc(20*time.Millisecond, func() { close(release) })
+	mutex.Lock()
+	mutex.Unlock()
+
+	if recorded := reports(); len(recorded) != 0 {
+		t.Errorf("recorded reports %v without a wait threshold, want none", recorded)
+	}
+}
@@ -0,0 +1,101 @@
+package stacktrace
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// closureSegmentRegexp matches a function name segment generated for a closure.
+	closureSegmentRegexp = regexp.MustCompile(`^(func|gowrap|deferwrap)\d+$`)
+	// rangeClosureRegexp matches the suffix generated for the body of a range-over-func loop.
+	rangeClosureRegexp = regexp.MustCompile(`-range\d+`)
+)
+
+// NormalizeConfig holds the configuration of Frames.Normalize.
+type NormalizeConfig struct {
+	// Root is the directory, typically the module root, that file paths are made relative to.
+	Root string
+	// Module is the module path that file paths built with -trimpath are made relative to.
+	Module string
+	// MaskLines sets the line numbers to 0, so that moving code does not change the frames.
+	MaskLines bool
+	// KeepExternal keeps the frames of files outside Root and Module, such as the standard
+	// library and dependencies, whose paths and lines depend on the environment. They are
+	// dropped if Root or Module is set and KeepExternal is false.
+	KeepExternal bool
+}
+
+// Normalize returns the frames with stable paths, function names and lines, for comparison
+// across machines and edits: file paths are made relative to the root or the module,
+// closure indices such as "func1" or "-range2" are replaced by "funcN" and "-rangeN",
+// program counters are cleared and line numbers are masked if configured.
+func (frames Frames) Normalize(config *NormalizeConfig) Frames {
+	if config == nil {
+		config = &NormalizeConfig{KeepExternal: true}
+	}
+	scoped := config.Root != "" || config.Module != ""
+	normalized := make(Frames, 0, len(frames))
+	for _, frame := range frames {
+		file, internal := config.relative(frame.File)
+		if scoped && !internal && !config.KeepExternal {
+			continue
+		}
+		frame.File = file
+		frame.Function = NormalizeClosure(frame.Function)
+		frame.PC = 0
+		if config.MaskLines {
+			frame.Line = 0
+		}
+		normalized = append(normalized, frame)
+	}
+	return normalized
+}
+
+// relative returns the file path relative to the root or the module, and whether it is inside.
+func (config *NormalizeConfig) relative(file string) (string, bool) {
+	if config.Root != "" {
+		root := filepath.ToSlash(filepath.Clean(config.Root)) + "/"
+		if rest, ok := strings.CutPrefix(file, root); ok {
+			return rest, true
+		}
+	}
+	if config.Module != "" {
+		if rest, ok := strings.CutPrefix(file, config.Module+"/"); ok {
+			return rest, true
+		}
+	}
+	return file, false
+}
+
+// NormalizeClosure replaces the indices the compiler assigns to closures in a function name,
+// such as "pkg.Outer.func2.1" or "pkg.Outer-range1", by "N", giving "pkg.Outer.funcN.N"
+// and "pkg.Outer-rangeN". Adding or removing a closure then leaves the other names unchanged.
+func NormalizeClosure(function string) string {
+	segments := strings.Split(function, ".")
+	for i, segment := range segments {
+		switch {
+		case i > 0 && isDigits(segment):
+			segments[i] = "N"
+		case closureSegmentRegexp.MatchString(segment):
+			segments[i] = strings.TrimRight(segment, "0123456789") + "N"
+		default:
+			segments[i] = rangeClosureRegexp.ReplaceAllString(segment, "-rangeN")
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// isDigits reports whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package stacktrace
+
+import "testing"
+
+func TestNormalizeClosure(t *testing.T) {
+	tests := map[string]string{
+		"stacktrace.TestNormalize":                "stacktrace.TestNormalize",
+		"stacktrace.TestNormalize.func12":         "stacktrace.TestNormalize.funcN",
+		"stacktrace.TestNormalize.func2.1":        "stacktrace.TestNormalize.funcN.N",
+		"stacktrace.(*Group).start.func1":         "stacktrace.(*Group).start.funcN",
+		"stacktrace.TestNormalize-range3":         "stacktrace.TestNormalize-rangeN",
+		"stacktrace.TestNormalize-range1.func2":   "stacktrace.TestNormalize-rangeN.funcN",
+		"stacktrace.TestNormalize.gowrap1":        "stacktrace.TestNormalize.gowrapN",
+		"stacktrace.TestNormalize.deferwrap2":     "stacktrace.TestNormalize.deferwrapN",
+		"stacktrace.handlerfunc1":                 "stacktrace.handlerfunc1",
+		"stacktrace.(*Tracker[go.shape.int]).Len": "stacktrace.(*Tracker[go.shape.int]).Len",
+		"v2.Parse": "v2.Parse",
+		"stacktrace.TestNormalize.func1.Method.12": "stacktrace.TestNormalize.funcN.Method.N",
+	}
+	for function, want := range tests {
+		if got := NormalizeClosure(function); got != want {
+			t.Errorf("NormalizeClosure(%q) = %q, want %q", function, got, want)
+		}
+	}
+}
+
+func TestFramesNormalize(t *testing.T) {
+	frames := Frames{
+		{Function: "stacktrace.load.func1", File: "/src/project/stacktrace/load.go", Line: 12, PC: 0x1234},
+		{Function: "http.HandlerFunc.ServeHTTP", File: "/usr/local/go/src/net/http/server.go", Line: 2220, PC: 0x5678},
+		{Function: "stacktrace.serve", File: "github.com/user/project/stacktrace/serve.go", Line: 40, PC: 0x9abc},
+	}
+
+	normalized := frames.Normalize(&NormalizeConfig{Root: "/src/project/", Module: "github.com/user/project", MaskLines: true})
+	want := Frames{
+		{Function: "stacktrace.load.funcN", File: "stacktrace/load.go"},
+		{Function: "stacktrace.serve", File: "stacktrace/serve.go"},
+	}
+	if !normalized.Equal(want) {
+		t.Errorf("Normalize() = %+v, want %+v", normalized, want)
+	}
+
+	kept := frames.Normalize(&NormalizeConfig{Root: "/src/project", KeepExternal: true})
+	if len(kept) != 3 || kept[1].File != frames[1].File || kept[1].Line != 2220 || kept[1].PC != 0 {
+		t.Errorf("Normalize() with KeepExternal = %+v", kept)
+	}
+
+	if unscoped := frames.Normalize(nil); len(unscoped) != 3 || unscoped[0].File != frames[0].File {
+		t.Errorf("Normalize(nil) = %+v, want the frames with normalized closures", unscoped)
+	}
+}
@@ -0,0 +1,72 @@
+package stacktrace
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// OpenTelemetry semantic convention names of the exception span event.
+const (
+	OTelExceptionEventName  = "exception"
+	OTelExceptionType       = "exception.type"
+	OTelExceptionMessage    = "exception.message"
+	OTelExceptionStacktrace = "exception.stacktrace"
+)
+
+// OTelEvent is a span event in the OTLP JSON encoding.
+type OTelEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []OTelAttribute `json:"attributes"`
+}
+
+// OTelAttribute is a key-value attribute in the OTLP JSON encoding.
+type OTelAttribute struct {
+	Key   string       `json:"key"`
+	Value OTelAnyValue `json:"value"`
+}
+
+// OTelAnyValue is an attribute value in the OTLP JSON encoding. Only string values are used.
+type OTelAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// OTelExceptionAttributes returns the exception.type, exception.message and
+// exception.stacktrace attributes of the OpenTelemetry exception semantic conventions.
+// The stack trace attribute holds the raw stack trace text, or the filtered frames
+// if it was not captured.
+func OTelExceptionAttributes(err error, stackTrace *StackTrace) map[string]string {
+	attributes := map[string]string{
+		OTelExceptionType:    "error",
+		OTelExceptionMessage: "unknown error",
+	}
+	if err != nil {
+		attributes[OTelExceptionType] = fmt.Sprintf("%T", err)
+		attributes[OTelExceptionMessage] = err.Error()
+	}
+	if stackTrace != nil {
+		if stackTrace.String() != "" {
+			attributes[OTelExceptionStacktrace] = stackTrace.String()
+		} else {
+			attributes[OTelExceptionStacktrace] = stackTrace.Frames().String()
+		}
+	}
+	return attributes
+}
+
+// NewOTelExceptionEvent converts an error and the stack trace captured for it into an
+// OpenTelemetry "exception" span event.
+func NewOTelExceptionEvent(err error, stackTrace *StackTrace) *OTelEvent {
+	attributes := OTelExceptionAttributes(err, stackTrace)
+	event := &OTelEvent{
+		TimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		Name:         OTelExceptionEventName,
+	}
+	for _, key := range []string{OTelExceptionType, OTelExceptionMessage, OTelExceptionStacktrace} {
+		if value, ok := attributes[key]; ok {
+			event.Attributes = append(event.Attributes, OTelAttribute{Key: key, Value: OTelAnyValue{StringValue: value}})
+		}
+	}
+	return event
+}
@@ -0,0 +1,47 @@
+package stacktrace
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOTelExceptionAttributes(t *testing.T) {
+	st := NewStackTrace(&Config{BufferSize: 2048, SkipFrames: 0})
+	attributes := OTelExceptionAttributes(errors.New("boom"), st)
+
+	if attributes[OTelExceptionType] != "*errors.errorString" {
+		t.Errorf("exception.type = %q, want %q", attributes[OTelExceptionType], "*errors.errorString")
+	}
+	if attributes[OTelExceptionMessage] != "boom" {
+		t.Errorf("exception.message = %q, want %q", attributes[OTelExceptionMessage], "boom")
+	}
+	if attributes[OTelExceptionStacktrace] != st.String() {
+		t.Error("exception.stacktrace is not the raw stack trace")
+	}
+
+	frames := &StackTrace{frames: Frames{{Function: "main.main", File: "/src/main.go", Line: 3}}}
+	if attributes := OTelExceptionAttributes(nil, frames); attributes[OTelExceptionStacktrace] != "/src/main.go:3 main.main" {
+		t.Errorf("exception.stacktrace = %q, want the frames", attributes[OTelExceptionStacktrace])
+	}
+	if _, ok := OTelExceptionAttributes(nil, nil)[OTelExceptionStacktrace]; ok {
+		t.Error("exception.stacktrace is set without a stack trace")
+	}
+}
+
+func TestNewOTelExceptionEvent(t *testing.T) {
+	event := NewOTelExceptionEvent(errors.New("boom"), NewStackTrace(&Config{BufferSize: 2048, SkipFrames: 0}))
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("json.Marshal returned error: %v", err)
+	}
+	for _, expected := range []string{`"name":"exception"`, `{"key":"exception.message","value":{"stringValue":"boom"}}`, `"key":"exception.stacktrace"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("event JSON does not contain %s: %s", expected, data)
+		}
+	}
+	if event.Attributes[0].Key != OTelExceptionType {
+		t.Errorf("first attribute is %q, want %q", event.Attributes[0].Key, OTelExceptionType)
+	}
+}
@@ -0,0 +1,125 @@
+package stacktrace
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+)
+
+// PanicKind classifies a panic value.
+type PanicKind int
+
+const (
+	// PanicOther is the kind of panic values of any other type.
+	PanicOther PanicKind = iota
+	// PanicString is the kind of string panic values, as in panic("message").
+	PanicString
+	// PanicCustomError is the kind of error panic values that are not runtime errors.
+	PanicCustomError
+	// PanicNilDereference is the kind of nil pointer dereferences.
+	PanicNilDereference
+	// PanicIndexOutOfRange is the kind of out of range indexes and slice bounds.
+	PanicIndexOutOfRange
+	// PanicDivideByZero is the kind of integer divisions by zero.
+	PanicDivideByZero
+	// PanicTypeAssertion is the kind of failed type assertions.
+	PanicTypeAssertion
+	// PanicNilMap is the kind of assignments to entries in nil maps.
+	PanicNilMap
+	// PanicRuntimeError is the kind of any other runtime error.
+	PanicRuntimeError
+)
+
+var (
+	// panicKindNames are the names of the panic kinds.
+	panicKindNames = map[PanicKind]string{
+		PanicOther:           "other",
+		PanicString:          "string",
+		PanicCustomError:     "error",
+		PanicNilDereference:  "nil dereference",
+		PanicIndexOutOfRange: "index out of range",
+		PanicDivideByZero:    "divide by zero",
+		PanicTypeAssertion:   "type assertion",
+		PanicNilMap:          "nil map",
+		PanicRuntimeError:    "runtime error",
+	}
+
+	// runtimeErrorKinds maps fragments of runtime error messages to the kinds they denote.
+	runtimeErrorKinds = []struct {
+		fragment string
+		kind     PanicKind
+	}{
+		{"nil pointer dereference", PanicNilDereference},
+		{"index out of range", PanicIndexOutOfRange},
+		{"slice bounds out of range", PanicIndexOutOfRange},
+		{"integer divide by zero", PanicDivideByZero},
+		{"assignment to entry in nil map", PanicNilMap},
+	}
+)
+
+// String returns the name of the panic kind.
+func (kind PanicKind) String() string {
+	if name, ok := panicKindNames[kind]; ok {
+		return name
+	}
+	return panicKindNames[PanicOther]
+}
+
+// RuntimeError reports whether the kind is a runtime.Error.
+func (kind PanicKind) RuntimeError() bool {
+	return kind >= PanicNilDereference && kind <= PanicRuntimeError
+}
+
+// ClassifyPanic returns the kind of a recovered panic value. Runtime errors are classified
+// by their type and message, since the runtime does not export their concrete types.
+func ClassifyPanic(value any) PanicKind {
+	switch value := value.(type) {
+	case string:
+		return PanicString
+	case runtime.Error:
+		var typeAssertionError *runtime.TypeAssertionError
+		if errors.As(value, &typeAssertionError) {
+			return PanicTypeAssertion
+		}
+		message := value.Error()
+		for _, runtimeErrorKind := range runtimeErrorKinds {
+			if strings.Contains(message, runtimeErrorKind.fragment) {
+				return runtimeErrorKind.kind
+			}
+		}
+		return PanicRuntimeError
+	case error:
+		return PanicCustomError
+	}
+	return PanicOther
+}
+
+// TrimPanic returns the frames starting at the frame that caused the innermost panic, such
+// as the function calling panic or dereferencing a nil pointer. The frames of the deferred
+// calls, runtime.gopanic and the runtime functions raising the panic, such as
+// runtime.panicmem, runtime.sigpanic or runtime.goPanicIndex, are skipped whatever their
+// number. The frames are returned unchanged if they do not contain runtime.gopanic.
+func TrimPanic(frames Frames) Frames {
+	for i, frame := range frames {
+		if frame.Function != "runtime.gopanic" {
+			continue
+		}
+		for i++; i < len(frames) && strings.HasPrefix(frames[i].Function, "runtime."); i++ {
+		}
+		return frames[i:]
+	}
+	return frames
+}
+
+// NewPanicStackTrace creates a stack trace starting at the frame that caused the panic
+// being recovered, as trimmed by TrimPanic. It must be called by the deferred function
+// that recovered the panic, or by a function it calls. SkipFrames of the configuration
+// is ignored, since the number of frames to skip depends on how the panic was raised.
+func NewPanicStackTrace(config *Config) *StackTrace {
+	if config == nil {
+		config = &DefaultConfig
+	}
+	stackTrace := NewStackTrace(&Config{BufferSize: config.BufferSize, SkipFrames: 1}) // +1 to skip NewPanicStackTrace
+	stackTrace.frames = TrimPanic(stackTrace.frames)
+	return stackTrace
+}
@@ -0,0 +1,114 @@
+package stacktrace
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type panicTarget struct{ value int }
+
+func panicNilDereference() {
+	var target *panicTarget
+	fmt.Println(target.value)
+}
+
+func panicIndexOutOfRange() {
+	values := []int{1, 2, 3}
+	index := 5
+	fmt.Println(values[index])
+}
+
+func panicDivideByZero() {
+	divisor := 0
+	fmt.Println(1 / divisor)
+}
+
+func panicTypeAssertion() {
+	var value any = "text"
+	fmt.Println(value.(int))
+}
+
+func panicNilMap() {
+	var values map[string]int
+	values["key"] = 1
+}
+
+func panicCustomError() {
+	panic(errors.New("custom"))
+}
+
+func panicString() {
+	panic("message")
+}
+
+func panicOther() {
+	panic(42)
+}
+
+func TestPanicClassification(t *testing.T) {
+	tests := []struct {
+		fn       func()
+		function string
+		kind     PanicKind
+	}{
+		{panicNilDereference, "stacktrace.panicNilDereference", PanicNilDereference},
+		{panicIndexOutOfRange, "stacktrace.panicIndexOutOfRange", PanicIndexOutOfRange},
+		{panicDivideByZero, "stacktrace.panicDivideByZero", PanicDivideByZero},
+		{panicTypeAssertion, "stacktrace.panicTypeAssertion", PanicTypeAssertion},
+		{panicNilMap, "stacktrace.panicNilMap", PanicNilMap},
+		{panicCustomError, "stacktrace.panicCustomError", PanicCustomError},
+		{panicString, "stacktrace.panicString", PanicString},
+		{panicOther, "stacktrace.panicOther", PanicOther},
+	}
+	for _, test := range tests {
+		t.Run(test.kind.String(), func(t *testing.T) {
+			panicError := catch(test.fn)
+			if panicError == nil {
+				t.Fatal("catch returned nil for a panicking function")
+			}
+			if panicError.Kind != test.kind || ClassifyPanic(panicError.Value) != test.kind {
+				t.Errorf("panic %v classified as %s, want %s", panicError.Value, panicError.Kind, test.kind)
+			}
+			if frames := panicError.Stack.Frames(); len(frames) == 0 || frames[0].Function != test.function {
+				t.Errorf("panic stack does not start at the faulting frame %s:\n%s", test.function, frames)
+			}
+		})
+	}
+}
+
+func TestPanicKindRuntimeError(t *testing.T) {
+	if !PanicNilDereference.RuntimeError() || !PanicRuntimeError.RuntimeError() {
+		t.Error("runtime error kinds are not reported as runtime errors")
+	}
+	for _, kind := range []PanicKind{PanicDivideByZero, PanicIndexOutOfRange, PanicTypeAssertion, PanicNilMap} {
+		if !kind.RuntimeError() {
+			t.Errorf("%s is not reported as a runtime error", kind)
+		}
+	}
+	for _, kind := range []PanicKind{PanicOther, PanicString, PanicCustomError, PanicRuntimeError + 1, PanicKind(100), PanicKind(-1)} {
+		if kind.RuntimeError() {
+			t.Errorf("PanicKind(%d) is reported as a runtime error", int(kind))
+		}
+	}
+	if PanicKind(100).String() != "other" {
+		t.Errorf("PanicKind(100).String() = %q, want %q", PanicKind(100).String(), "other")
+	}
+}
+
+func TestTrimPanic(t *testing.T) {
+	frames := Frames{
+		{Function: "stacktrace.recoverer"},
+		{Function: "runtime.gopanic"},
+		{Function: "runtime.panicmem"},
+		{Function: "runtime.sigpanic"},
+		{Function: "stacktrace.fault"},
+		{Function: "testing.tRunner"},
+	}
+	if trimmed := TrimPanic(frames); !trimmed.Equal(frames[4:]) {
+		t.Errorf("TrimPanic() = %v, want the frames from the faulting frame", trimmed)
+	}
+	if trimmed := TrimPanic(frames[4:]); !trimmed.Equal(frames[4:]) {
+		t.Errorf("TrimPanic() without runtime.gopanic = %v, want the frames unchanged", trimmed)
+	}
+}
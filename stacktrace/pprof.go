@@ -0,0 +1,421 @@
+package stacktrace
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Protocol buffer wire types used by the pprof profile format.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var (
+	// ErrInvalidProfile is returned when a pprof profile cannot be decoded.
+	ErrInvalidProfile = errors.New("stacktrace: invalid pprof profile")
+)
+
+// PprofProfile is a profile written by runtime/pprof, such as a goroutine, block or mutex profile.
+type PprofProfile struct {
+	SampleTypes []string      // Sample value types as "type/unit", e.g. "contentions/count".
+	Samples     []PprofSample // Samples of the profile.
+}
+
+// PprofSample is a single sample of a pprof profile.
+type PprofSample struct {
+	Frames    Frames            // Filtered frames of the sample, innermost first.
+	Values    []int64           // Values of the sample, one per sample type.
+	Labels    map[string]string // String labels of the sample, e.g. set with pprof.Do.
+	NumLabels map[string]int64  // Numeric labels of the sample.
+}
+
+// Count returns the first value of the sample, which is the sample count for goroutine profiles.
+func (sample PprofSample) Count() int64 {
+	if len(sample.Values) == 0 {
+		return 0
+	}
+	return sample.Values[0]
+}
+
+// ParsePprof decodes a profile in the gzipped or plain protocol buffer format written by
+// pprof.Profile.WriteTo with debug set to 0.
+func ParsePprof(r io.Reader) (*PprofProfile, error) {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	} else {
+		r = reader
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+	}
+	profile, err := decodePprof(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+	}
+	return profile, nil
+}
+
+// AddPprof records every sample of the profile, weighted by the value at the given sample type index.
+func (profile *Profile) AddPprof(pprofProfile *PprofProfile, index int) {
+	for _, sample := range pprofProfile.Samples {
+		if index < len(sample.Values) {
+			profile.Add(sample.Frames, sample.Values[index])
+		}
+	}
+}
+
+// pprofSample is a sample as encoded in the profile, referencing locations and strings by ID.
+type pprofSample struct {
+	locationIDs []uint64
+	values      []int64
+	labels      [][3]int64 // Key, string value and numeric value.
+}
+
+// pprofLine is a line of a location, referencing a function by ID.
+type pprofLine struct {
+	functionID uint64
+	line       int64
+}
+
+// pprofFunction is a function as encoded in the profile, referencing strings by index.
+type pprofFunction struct {
+	name     int64
+	filename int64
+}
+
+// decodePprof decodes an uncompressed profile message.
+func decodePprof(data []byte) (*PprofProfile, error) {
+	var (
+		sampleTypes [][2]int64
+		samples     []pprofSample
+		locations   = make(map[uint64][]pprofLine)
+		functions   = make(map[uint64]pprofFunction)
+		stringTable []string
+	)
+
+	reader := protoReader{data: data}
+	for !reader.done() {
+		field, wireType, err := reader.key()
+		if err != nil {
+			return nil, err
+		}
+		if wireType != wireBytes {
+			if err := reader.skip(wireType); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		message, err := reader.bytes()
+		if err != nil {
+			return nil, err
+		}
+		switch field {
+		case 1: // sample_type
+			valueType, err := decodeValueType(message)
+			if err != nil {
+				return nil, err
+			}
+			sampleTypes = append(sampleTypes, valueType)
+		case 2: // sample
+			sample, err := decodeSample(message)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, sample)
+		case 4: // location
+			id, lines, err := decodeLocation(message)
+			if err != nil {
+				return nil, err
+			}
+			locations[id] = lines
+		case 5: // function
+			id, function, err := decodeFunction(message)
+			if err != nil {
+				return nil, err
+			}
+			functions[id] = function
+		case 6: // string_table
+			stringTable = append(stringTable, string(message))
+		}
+	}
+
+	lookup := func(index int64) string {
+		if index < 0 || index >= int64(len(stringTable)) {
+			return ""
+		}
+		return stringTable[index]
+	}
+
+	profile := &PprofProfile{
+		SampleTypes: make([]string, 0, len(sampleTypes)),
+		Samples:     make([]PprofSample, 0, len(samples)),
+	}
+	for _, valueType := range sampleTypes {
+		profile.SampleTypes = append(profile.SampleTypes, lookup(valueType[0])+"/"+lookup(valueType[1]))
+	}
+	for _, sample := range samples {
+		var frames Frames
+		for _, locationID := range sample.locationIDs {
+			for _, line := range locations[locationID] {
+				function := functions[line.functionID]
+				frames = append(frames, Frame{
+					Function: lookup(function.name),
+					File:     lookup(function.filename),
+					Line:     int(line.line),
+				})
+			}
+		}
+		decoded := PprofSample{
+			Frames: frames.filter(),
+			Values: sample.values,
+		}
+		for _, label := range sample.labels {
+			if label[1] != 0 {
+				if decoded.Labels == nil {
+					decoded.Labels = make(map[string]string)
+				}
+				decoded.Labels[lookup(label[0])] = lookup(label[1])
+			} else {
+				if decoded.NumLabels == nil {
+					decoded.NumLabels = make(map[string]int64)
+				}
+				decoded.NumLabels[lookup(label[0])] = label[2]
+			}
+		}
+		profile.Samples = append(profile.Samples, decoded)
+	}
+	return profile, nil
+}
+
+// decodeValueType decodes a ValueType message into its type and unit string indexes.
+func decodeValueType(data []byte) ([2]int64, error) {
+	var valueType [2]int64
+	err := decodeMessage(data, func(reader *protoReader, field, wireType int) error {
+		if field != 1 && field != 2 || wireType != wireVarint {
+			return reader.skip(wireType)
+		}
+		value, err := reader.varint()
+		valueType[field-1] = int64(value)
+		return err
+	})
+	return valueType, err
+}
+
+// decodeSample decodes a Sample message.
+func decodeSample(data []byte) (pprofSample, error) {
+	var sample pprofSample
+	err := decodeMessage(data, func(reader *protoReader, field, wireType int) error {
+		switch field {
+		case 1: // location_id
+			return reader.repeatedVarint(wireType, func(value uint64) {
+				sample.locationIDs = append(sample.locationIDs, value)
+			})
+		case 2: // value
+			return reader.repeatedVarint(wireType, func(value uint64) {
+				sample.values = append(sample.values, int64(value))
+			})
+		case 3: // label
+			if wireType != wireBytes {
+				return reader.skip(wireType)
+			}
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			var label [3]int64
+			err = decodeMessage(message, func(reader *protoReader, field, wireType int) error {
+				if field < 1 || field > 3 || wireType != wireVarint {
+					return reader.skip(wireType)
+				}
+				value, err := reader.varint()
+				label[field-1] = int64(value)
+				return err
+			})
+			sample.labels = append(sample.labels, label)
+			return err
+		}
+		return reader.skip(wireType)
+	})
+	return sample, err
+}
+
+// decodeLocation decodes a Location message into its ID and lines, innermost first.
+func decodeLocation(data []byte) (uint64, []pprofLine, error) {
+	var id uint64
+	var lines []pprofLine
+	err := decodeMessage(data, func(reader *protoReader, field, wireType int) error {
+		switch {
+		case field == 1 && wireType == wireVarint: // id
+			value, err := reader.varint()
+			id = value
+			return err
+		case field == 4 && wireType == wireBytes: // line
+			message, err := reader.bytes()
+			if err != nil {
+				return err
+			}
+			var line pprofLine
+			err = decodeMessage(message, func(reader *protoReader, field, wireType int) error {
+				if wireType != wireVarint || field != 1 && field != 2 {
+					return reader.skip(wireType)
+				}
+				value, err := reader.varint()
+				if field == 1 {
+					line.functionID = value
+				} else {
+					line.line = int64(value)
+				}
+				return err
+			})
+			lines = append(lines, line)
+			return err
+		}
+		return reader.skip(wireType)
+	})
+	return id, lines, err
+}
+
+// decodeFunction decodes a Function message into its ID and string indexes.
+func decodeFunction(data []byte) (uint64, pprofFunction, error) {
+	var id uint64
+	var function pprofFunction
+	err := decodeMessage(data, func(reader *protoReader, field, wireType int) error {
+		if wireType != wireVarint {
+			return reader.skip(wireType)
+		}
+		value, err := reader.varint()
+		switch field {
+		case 1: // id
+			id = value
+		case 2: // name
+			function.name = int64(value)
+		case 4: // filename
+			function.filename = int64(value)
+		}
+		return err
+	})
+	return id, function, err
+}
+
+// decodeMessage calls fn for every field of the message. fn must consume the field value.
+func decodeMessage(data []byte, fn func(reader *protoReader, field, wireType int) error) error {
+	reader := protoReader{data: data}
+	for !reader.done() {
+		field, wireType, err := reader.key()
+		if err != nil {
+			return err
+		}
+		if err := fn(&reader, field, wireType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// protoReader is a minimal reader of the protocol buffer wire format.
+type protoReader struct {
+	data []byte
+}
+
+// done reports whether all data has been consumed.
+func (reader *protoReader) done() bool {
+	return len(reader.data) == 0
+}
+
+// key reads a field key and returns its field number and wire type.
+func (reader *protoReader) key() (int, int, error) {
+	value, err := reader.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(value >> 3), int(value & 7), nil
+}
+
+// varint reads a base-128 varint.
+func (reader *protoReader) varint() (uint64, error) {
+	value, n := binary.Uvarint(reader.data)
+	if n <= 0 {
+		return 0, errors.New("malformed varint")
+	}
+	reader.data = reader.data[n:]
+	return value, nil
+}
+
+// bytes reads a length-delimited value.
+func (reader *protoReader) bytes() ([]byte, error) {
+	length, err := reader.varint()
+	if err != nil {
+		return nil, err
+	}
+	if length > uint64(len(reader.data)) {
+		return nil, errors.New("truncated length-delimited field")
+	}
+	value := reader.data[:length]
+	reader.data = reader.data[length:]
+	return value, nil
+}
+
+// repeatedVarint reads a repeated varint field in either packed or unpacked encoding.
+func (reader *protoReader) repeatedVarint(wireType int, fn func(uint64)) error {
+	switch wireType {
+	case wireVarint:
+		value, err := reader.varint()
+		if err == nil {
+			fn(value)
+		}
+		return err
+	case wireBytes:
+		packed, err := reader.bytes()
+		if err != nil {
+			return err
+		}
+		packedReader := protoReader{data: packed}
+		for !packedReader.done() {
+			value, err := packedReader.varint()
+			if err != nil {
+				return err
+			}
+			fn(value)
+		}
+		return nil
+	}
+	return reader.skip(wireType)
+}
+
+// skip discards a value of the given wire type.
+func (reader *protoReader) skip(wireType int) error {
+	var size int
+	switch wireType {
+	case wireVarint:
+		_, err := reader.varint()
+		return err
+	case wireBytes:
+		_, err := reader.bytes()
+		return err
+	case wireFixed64:
+		size = 8
+	case wireFixed32:
+		size = 4
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	if len(reader.data) < size {
+		return errors.New("truncated fixed-size field")
+	}
+	reader.data = reader.data[size:]
+	return nil
+}
@@ -0,0 +1,151 @@
+package stacktrace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pprofBlockedWorker(started chan<- struct{}, release <-chan struct{}) {
+	close(started)
+	<-release
+}
+
+func TestParsePprofGoroutine(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	pprof.Do(context.Background(), pprof.Labels("worker", "pprof-test"), func(context.Context) {
+		go pprofBlockedWorker(started, release)
+	})
+	<-started
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		t.Fatalf("writing goroutine profile: %v", err)
+	}
+	profile, err := ParsePprof(&buf)
+	if err != nil {
+		t.Fatalf("ParsePprof() = %v", err)
+	}
+	if len(profile.SampleTypes) != 1 || profile.SampleTypes[0] != "goroutine/count" {
+		t.Errorf("SampleTypes = %v, want [goroutine/count]", profile.SampleTypes)
+	}
+
+	var found *PprofSample
+	for i, sample := range profile.Samples {
+		if sample.Labels["worker"] == "pprof-test" {
+			found = &profile.Samples[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("no sample with the pprof.Do labels in %d samples", len(profile.Samples))
+	}
+	if found.Count() != 1 || !strings.Contains(found.Frames.String(), "stacktrace.pprofBlockedWorker") {
+		t.Errorf("labeled sample = count %d, frames:\n%s", found.Count(), found.Frames)
+	}
+	for _, frame := range found.Frames {
+		if frame.File == "" || frame.Line < 1 {
+			t.Errorf("sample frame without location: %+v", frame)
+		}
+	}
+}
+
+func TestParsePprofBlock(t *testing.T) {
+	runtime.SetBlockProfileRate(1)
+	defer runtime.SetBlockProfileRate(0)
+
+	ready := make(chan struct{})
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		close(ready)
+	}()
+	<-ready
+
+	var buf bytes.Buffer
+	if err := pprof.Lookup("block").WriteTo(&buf, 0); err != nil {
+		t.Fatalf("writing block profile: %v", err)
+	}
+	profile, err := ParsePprof(&buf)
+	if err != nil {
+		t.Fatalf("ParsePprof() = %v", err)
+	}
+	if strings.Join(profile.SampleTypes, ",") != "contentions/count,delay/nanoseconds" {
+		t.Errorf("SampleTypes = %v, want contentions and delay", profile.SampleTypes)
+	}
+	for _, sample := range profile.Samples {
+		if strings.Contains(sample.Frames.String(), "stacktrace.TestParsePprofBlock") {
+			if len(sample.Values) != 2 || sample.Values[0] < 1 || sample.Values[1] <= 0 {
+				t.Errorf("block sample values = %v, want a contention count and a delay", sample.Values)
+			}
+			return
+		}
+	}
+	t.Errorf("no block sample from the test in %d samples", len(profile.Samples))
+}
+
+func TestProfileAddPprof(t *testing.T) {
+	pprofProfile := &PprofProfile{
+		SampleTypes: []string{"contentions/count", "delay/nanoseconds"},
+		Samples: []PprofSample{
+			{Frames: Frames{{Function: "main.lock"}, {Function: "main.main"}}, Values: []int64{2, 300}},
+			{Frames: Frames{{Function: "main.wait"}, {Function: "main.main"}}, Values: []int64{5, 100}},
+			{Frames: Frames{{Function: "main.short"}}, Values: []int64{1}},
+		},
+	}
+
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "main.main;main.lock 2\nmain.main;main.wait 5\nmain.short 1\n"},
+		{1, "main.main;main.lock 300\nmain.main;main.wait 100\n"},
+	}
+	for _, test := range tests {
+		profile := NewProfile()
+		profile.AddPprof(pprofProfile, test.index)
+		var buf bytes.Buffer
+		if err := profile.WriteFolded(&buf); err != nil {
+			t.Fatalf("WriteFolded() = %v", err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("AddPprof(index %d) folded to %q, want %q", test.index, buf.String(), test.want)
+		}
+	}
+}
+
+func TestParsePprofInvalid(t *testing.T) {
+	var truncatedGzip bytes.Buffer
+	writer := gzip.NewWriter(&truncatedGzip)
+	_, _ = writer.Write([]byte{0x0a, 0x02, 0x08, 0x01})
+	_ = writer.Close()
+
+	tests := map[string][]byte{
+		"truncated varint":   {0x80},
+		"truncated key":      {0x0a, 0x80},
+		"bad length":         {0x0a, 0x0a, 0x08, 0x01},
+		"nested bad length":  {0x12, 0x02, 0x0a, 0x05},
+		"unknown wire type":  {0x0b, 0x01},
+		"truncated fixed64":  {0x09, 0x01, 0x02},
+		"truncated gzip":     truncatedGzip.Bytes()[:truncatedGzip.Len()-4],
+		"corrupt gzip":       {0x1f, 0x8b, 0x00},
+		"unknown nested key": {0x2a, 0x02, 0x0b, 0x01},
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParsePprof(bytes.NewReader(data)); !errors.Is(err, ErrInvalidProfile) {
+				t.Errorf("ParsePprof() = %v, want ErrInvalidProfile", err)
+			}
+		})
+	}
+
+	if profile, err := ParsePprof(bytes.NewReader(nil)); err != nil || len(profile.Samples) != 0 {
+		t.Errorf("ParsePprof(empty) = %+v, %v, want an empty profile", profile, err)
+	}
+}
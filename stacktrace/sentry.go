@@ -0,0 +1,179 @@
+package stacktrace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SentryEvent is an error event in the Sentry event payload schema.
+type SentryEvent struct {
+	EventID   string            `json:"event_id"`
+	Timestamp string            `json:"timestamp"`
+	Platform  string            `json:"platform"`
+	Level     string            `json:"level"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Exception SentryExceptions  `json:"exception"`
+}
+
+// SentryExceptions is the exception interface of a Sentry event.
+type SentryExceptions struct {
+	Values []SentryException `json:"values"`
+}
+
+// SentryException is a single exception of a Sentry event.
+type SentryException struct {
+	Type       string            `json:"type"`
+	Value      string            `json:"value"`
+	Module     string            `json:"module,omitempty"`
+	Stacktrace *SentryStacktrace `json:"stacktrace,omitempty"`
+}
+
+// SentryStacktrace is the stack trace of a Sentry exception, with frames ordered oldest first.
+type SentryStacktrace struct {
+	Frames []SentryFrame `json:"frames"`
+}
+
+// SentryFrame is a single frame of a Sentry stack trace.
+type SentryFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// NewSentryEvent converts an error and the stack trace captured for it into a Sentry event.
+// Every error of the Unwrap chain becomes an exception, innermost first as Sentry expects,
+// and the stack trace is attached to the outermost one. The pprof labels of the stack
+// trace become event tags.
+func NewSentryEvent(err error, stackTrace *StackTrace) *SentryEvent {
+	event := &SentryEvent{
+		EventID:   newEventID(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Platform:  "go",
+		Level:     "error",
+	}
+
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		event.Exception.Values = append([]SentryException{{
+			Type:  fmt.Sprintf("%T", cause),
+			Value: cause.Error(),
+		}}, event.Exception.Values...)
+	}
+	if len(event.Exception.Values) == 0 {
+		event.Exception.Values = []SentryException{{Type: "error", Value: "unknown error"}}
+	}
+
+	if stackTrace != nil {
+		outermost := &event.Exception.Values[len(event.Exception.Values)-1]
+		outermost.Stacktrace = newSentryStacktrace(stackTrace.Frames())
+		if len(outermost.Stacktrace.Frames) > 0 {
+			outermost.Module = outermost.Stacktrace.Frames[len(outermost.Stacktrace.Frames)-1].Module
+		}
+		if len(stackTrace.Labels()) > 0 {
+			event.Tags = stackTrace.Labels()
+		}
+	}
+	return event
+}
+
+// newSentryStacktrace converts frames, innermost first, into a Sentry stack trace.
+func newSentryStacktrace(frames Frames) *SentryStacktrace {
+	sentryFrames := make([]SentryFrame, 0, len(frames))
+	for i := len(frames) - 1; i >= 0; i-- {
+		module, function, known := splitFunction(frames[i])
+		sentryFrames = append(sentryFrames, SentryFrame{
+			Function: function,
+			Module:   module,
+			Filename: filepath.Base(frames[i].File),
+			AbsPath:  frames[i].File,
+			Lineno:   frames[i].Line,
+			InApp:    isInApp(frames[i].File, module, known),
+		})
+	}
+	return &SentryStacktrace{Frames: sentryFrames}
+}
+
+// splitFunction splits the function of a frame into its package import path and its name,
+// such as "github.com/user/app/db" and "(*Conn).Query". The full name is resolved from the
+// program counter; without one, the normalized name only gives the last path element of
+// the package, and known is false.
+func splitFunction(frame Frame) (module, function string, known bool) {
+	if frame.PC != 0 {
+		if fn := runtime.FuncForPC(frame.PC); fn != nil && NormalizeFunction(fn.Name()) == frame.Function {
+			name := fn.Name()
+			rest := name[strings.LastIndexByte(name, '/')+1:]
+			return packagePath(name), rest[strings.IndexByte(rest, '.')+1:], true
+		}
+	}
+	if i := strings.IndexByte(frame.Function, '.'); i > 0 {
+		return frame.Function[:i], frame.Function[i+1:], false
+	}
+	return "", frame.Function, false
+}
+
+var (
+	// mainModule is the path of the main module of the binary, if known.
+	mainModule = sync.OnceValue(func() string {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			return info.Main.Path
+		}
+		return ""
+	})
+
+	// stdSourceRoot is the directory of the standard library sources as recorded in the
+	// binary, such as "/usr/local/go/src", or empty if built with -trimpath.
+	stdSourceRoot = sync.OnceValue(func() string {
+		fn := runtime.FuncForPC(reflect.ValueOf(strings.Cut).Pointer())
+		if fn == nil {
+			return ""
+		}
+		file, _ := fn.FileLine(fn.Entry())
+		return strings.TrimSuffix(strings.TrimSuffix(filepath.ToSlash(file), "strings/strings.go"), "/")
+	})
+)
+
+// isInApp reports whether the frame belongs to the application rather than to the
+// standard library or a dependency. Dependencies are detected by their module cache,
+// versioned (as in "-trimpath" builds) or vendor paths; the standard library by its source
+// directory, or by a package path whose first element has no dot when the package is known.
+func isInApp(file, module string, known bool) bool {
+	if known && (module == "main" || mainModule() != "" && (module == mainModule() || strings.HasPrefix(module, mainModule()+"/"))) {
+		return true
+	}
+	file = filepath.ToSlash(file)
+	if strings.Contains(file, "/pkg/mod/") || strings.Contains(file, "/vendor/") || strings.Contains(file, "@v") {
+		return false
+	}
+	if root := stdSourceRoot(); root != "" && strings.HasPrefix(file, root+"/") {
+		return false
+	}
+	if !strings.HasPrefix(file, "/") && !filepath.IsAbs(file) && !firstElementHasDot(file) {
+		return false // Standard library file in a -trimpath build, such as "runtime/proc.go".
+	}
+	return !known || firstElementHasDot(module)
+}
+
+// firstElementHasDot reports whether the first element of a slash-separated path contains
+// a dot, as module paths do and standard library paths do not.
+func firstElementHasDot(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return strings.Contains(first, ".")
+}
+
+// newEventID returns a random 32 character hexadecimal event ID.
+func newEventID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,120 @@
+package stacktrace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewSentryEvent(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := fmt.Errorf("loading user: %w", cause)
+	st := &StackTrace{
+		frames: Frames{
+			{Function: "db.(*Conn).Query", File: "/src/app/db/conn.go", Line: 40},
+			{Function: "main.main", File: "/src/app/main.go", Line: 12},
+			{Function: "runtime.main", File: stdSourceRoot() + "/runtime/proc.go", Line: 283},
+		},
+		labels: map[string]string{"tenant": "acme"},
+	}
+
+	event := NewSentryEvent(err, st)
+	if len(event.EventID) != 32 || event.Platform != "go" || event.Level != "error" {
+		t.Errorf("unexpected event header: %+v", event)
+	}
+	if event.Tags["tenant"] != "acme" {
+		t.Errorf("SentryEvent.Tags = %v, want the stack trace labels", event.Tags)
+	}
+
+	values := event.Exception.Values
+	if len(values) != 2 {
+		t.Fatalf("event has %d exceptions, want 2", len(values))
+	}
+	if values[0].Value != "connection refused" || values[0].Stacktrace != nil {
+		t.Errorf("first exception is not the innermost cause: %+v", values[0])
+	}
+	if values[1].Type != "*fmt.wrapError" || values[1].Value != err.Error() || values[1].Module != "db" {
+		t.Errorf("unexpected outermost exception: %+v", values[1])
+	}
+
+	frames := values[1].Stacktrace.Frames
+	expected := []SentryFrame{
+		{Function: "main", Module: "runtime", Filename: "proc.go", AbsPath: stdSourceRoot() + "/runtime/proc.go", Lineno: 283, InApp: false},
+		{Function: "main", Module: "main", Filename: "main.go", AbsPath: "/src/app/main.go", Lineno: 12, InApp: true},
+		{Function: "(*Conn).Query", Module: "db", Filename: "conn.go", AbsPath: "/src/app/db/conn.go", Lineno: 40, InApp: true},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("stacktrace has %d frames, want %d", len(frames), len(expected))
+	}
+	for i := range expected {
+		if frames[i] != expected[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, frames[i], expected[i])
+		}
+	}
+
+	data, jsonErr := json.Marshal(event)
+	if jsonErr != nil {
+		t.Fatalf("json.Marshal returned error: %v", jsonErr)
+	}
+	var decoded map[string]any
+	if jsonErr := json.Unmarshal(data, &decoded); jsonErr != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", jsonErr)
+	}
+	frame := decoded["exception"].(map[string]any)["values"].([]any)[1].(map[string]any)["stacktrace"].(map[string]any)["frames"].([]any)[2].(map[string]any)
+	for _, key := range []string{"function", "module", "filename", "abs_path", "lineno", "in_app"} {
+		if _, ok := frame[key]; !ok {
+			t.Errorf("JSON frame does not contain %q: %s", key, data)
+		}
+	}
+}
+
+func TestNewSentryEventWithoutError(t *testing.T) {
+	event := NewSentryEvent(nil, nil)
+	if len(event.Exception.Values) != 1 || event.Exception.Values[0].Stacktrace != nil {
+		t.Errorf("unexpected exceptions for nil error: %+v", event.Exception.Values)
+	}
+}
+
+func TestIsInApp(t *testing.T) {
+	testCases := []struct {
+		file     string
+		module   string
+		known    bool
+		expected bool
+	}{
+		{"/src/app/main.go", "main", false, true},
+		{"/src/app/db/conn.go", "github.com/user/app/db", true, true},
+		{stdSourceRoot() + "/net/http/server.go", "http", false, false},
+		{"/home/user/go/pkg/mod/github.com/lib/pq@v1.0.0/conn.go", "pq", false, false},
+		{"/src/app/vendor/github.com/lib/pq/conn.go", "github.com/lib/pq", true, false},
+		{"runtime/proc.go", "runtime", true, false},
+		{"runtime/proc.go", "runtime", false, false},
+		{"github.com/lib/pq@v1.0.0/conn.go", "github.com/lib/pq", true, false},
+		{"github.com/user/app/db/conn.go", "github.com/user/app/db", true, true},
+		{"/build/go/src/net/http/server.go", "net/http", true, false},
+	}
+
+	for _, tc := range testCases {
+		if inApp := isInApp(tc.file, tc.module, tc.known); inApp != tc.expected {
+			t.Errorf("isInApp(%q, %q, %v) = %v, want %v", tc.file, tc.module, tc.known, inApp, tc.expected)
+		}
+	}
+}
+
+func TestSplitFunction(t *testing.T) {
+	frames := Callers(0, 2)
+	module, function, known := splitFunction(frames[0])
+	if module != "github.com/turtak/go-kit/stacktrace" || function != "TestSplitFunction" || !known {
+		t.Errorf("splitFunction(%+v) = %q, %q, %v", frames[0], module, function, known)
+	}
+	sentryFrames := newSentryStacktrace(frames).Frames
+	if sentryFrames[0].Module != "testing" || sentryFrames[0].InApp || !sentryFrames[1].InApp {
+		t.Errorf("newSentryStacktrace() = %+v, want testing out of app and the test in app", sentryFrames)
+	}
+
+	module, function, known = splitFunction(Frame{Function: "db.(*Conn).Query"})
+	if module != "db" || function != "(*Conn).Query" || known {
+		t.Errorf("splitFunction() without PC = %q, %q, %v", module, function, known)
+	}
+}
@@ -3,8 +3,6 @@
 package stacktrace
 
 import (
-	"fmt"
-	"regexp"
 	"runtime"
 	"strings"
 )
@@ -28,15 +26,13 @@ const (
 	validSuffix = ".go"
 )
 
-var (
-	// functionNameRegexp is the regular expression used to extract function names.
-	functionNameRegexp = regexp.MustCompile(`\/([^\/]+)$`)
-)
-
 // StackTrace represents a stack trace with frames and text representation.
 type StackTrace struct {
-	frames Frames // Filtered frames of the stack trace.
-	raw    string // Raw text representation of the stack trace.
+	frames      Frames            // Filtered frames of the stack trace.
+	raw         string            // Raw text representation of the stack trace.
+	ancestors   []Frames          // Creation-site stacks recorded by Go, nearest first.
+	goroutineID int64             // ID of the goroutine that captured the stack trace.
+	labels      map[string]string // pprof labels of the goroutine that captured the stack trace.
 }
 
 // Frames represents a collection of Frame objects.
@@ -46,41 +42,82 @@ type Frames []Frame
 func (frames Frames) filter() Frames {
 	filtered := make(Frames, 0, len(frames))
 	for _, frame := range frames {
-		if frame.File == "" || frame.Function == "" || frame.Line < 1 || !strings.HasSuffix(frame.File, validSuffix) {
+		if !frame.valid() {
 			continue
 		}
-		// Simplify function name extraction
-		functionName := frame.Function
-		if match := functionNameRegexp.FindStringSubmatch(frame.Function); len(match) == 2 {
-			functionName = match[1]
-		}
 		// Append the structured frame
 		filtered = append(filtered, Frame{
-			Function: functionName,
+			Function: NormalizeFunction(frame.Function),
 			File:     frame.File,
 			Line:     frame.Line,
+			PC:       frame.PC,
 		})
 	}
 	return filtered
 }
 
-// String returns the string representation of the frames.
-func (frames Frames) String() string {
-	var builder strings.Builder
-	for i, frame := range frames {
-		if i > 0 {
-			builder.WriteString("\n")
-		}
-		fmt.Fprintf(&builder, "%s:%d %s", frame.File, frame.Line, frame.Function)
+// NormalizeFunction strips the package path from a function name for readability,
+// as done for the frames of a StackTrace. It does not allocate.
+func NormalizeFunction(function string) string {
+	if slash := strings.LastIndexByte(function, '/'); slash >= 0 && slash < len(function)-1 {
+		return function[slash+1:]
 	}
-	return builder.String()
+	return function
+}
+
+// String returns the string representation of the frames, one "file:line function" line
+// per frame, with recursion collapsed as by CollapsedString with DefaultCollapseRepeats.
+func (frames Frames) String() string {
+	return frames.CollapsedString(DefaultCollapseRepeats)
 }
 
 // Frame represents a single function call in the stack trace.
 type Frame struct {
-	Function string // Name of the function.
-	File     string // File where the function is located.
-	Line     int    // Line number in the file.
+	Function string  `json:"function"`     // Name of the function.
+	File     string  `json:"file"`         // File where the function is located.
+	Line     int     `json:"line"`         // Line number in the file.
+	PC       uintptr `json:"pc,omitempty"` // Program counter of the call, if known.
+}
+
+// valid reports whether the frame has a function and a location in a Go source file.
+func (frame Frame) valid() bool {
+	return frame.File != "" && frame.Function != "" && frame.Line >= 1 && strings.HasSuffix(frame.File, validSuffix)
+}
+
+// callers captures at most size frames of the calling goroutine, skipping skip frames
+// above the caller of callers, and returns them unfiltered.
+func callers(skip, size int) Frames {
+	if size <= 0 {
+		return nil
+	}
+	uIntPtr := make([]uintptr, size)
+	n := runtime.Callers(skip+2, uIntPtr) // +2 to skip runtime.Callers and callers
+	return framesOf(uIntPtr[:n])
+}
+
+// framesOf resolves program counters returned by runtime.Callers into unfiltered frames.
+func framesOf(uIntPtr []uintptr) Frames {
+	if len(uIntPtr) == 0 {
+		return nil
+	}
+	// Extract the structured frames
+	frames := runtime.CallersFrames(uIntPtr)
+	structuredFrames := make(Frames, 0, len(uIntPtr))
+	for {
+		frame, more := frames.Next()
+		// Append the structured frame
+		structuredFrames = append(structuredFrames, Frame{
+			Function: frame.Function,
+			File:     frame.File,
+			Line:     frame.Line,
+			PC:       frame.PC,
+		})
+		// Break if no more frames
+		if !more {
+			break
+		}
+	}
+	return structuredFrames
 }
 
 // NewStackTrace creates a new stack trace starting from the given skip level.
@@ -95,35 +132,25 @@ func NewStackTrace(config *Config) *StackTrace {
 		config = &DefaultConfig
 	}
 
-	// Get the stack trace
-	uIntPtr := make([]uintptr, config.BufferSize)
-	n := runtime.Callers(config.SkipFrames+2, uIntPtr) // +2 to skip runtime.Callers and NewStackTrace
-	if n > 0 {
-		uIntPtr = uIntPtr[:n]
-		// Extract the structured frames
-		frames := runtime.CallersFrames(uIntPtr)
-		var structuredFrames Frames
-		for {
-			frame, more := frames.Next()
-			// Append the structured frame
-			structuredFrames = append(structuredFrames, Frame{
-				Function: frame.Function,
-				File:     frame.File,
-				Line:     frame.Line,
-			})
-			// Break if no more frames
-			if !more {
-				break
-			}
-		}
-		stackTrace.frames = structuredFrames.filter()
+	// Get the stack trace, +1 to skip NewStackTrace
+	if frames := callers(config.SkipFrames+1, config.BufferSize); len(frames) > 0 {
+		stackTrace.frames = frames.filter()
 	}
 
 	// Get the raw stack trace text
-	buf := make([]byte, config.BufferSize)
-	nBytes := runtime.Stack(buf, false)
-	if nBytes > 0 {
-		stackTrace.raw = strings.TrimSpace(string(buf[:nBytes]))
+	if config.BufferSize > 0 {
+		buf := make([]byte, config.BufferSize)
+		nBytes := runtime.Stack(buf, false)
+		if nBytes > 0 {
+			stackTrace.raw = strings.TrimSpace(string(buf[:nBytes]))
+		}
+	}
+
+	// Attach the goroutine metadata and the creation chain recorded by Go, if any
+	if header, ok := parseGoroutineHeader(strings.SplitN(stackTrace.raw, "\n", 2)[0]); ok {
+		stackTrace.goroutineID = header.ID
+		stackTrace.labels = header.Labels
+		stackTrace.ancestors = lookupAncestors(header.ID)
 	}
 
 	return stackTrace
@@ -145,7 +172,10 @@ func (stackTrace *StackTrace) Limit(n int) *StackTrace {
 		return stackTrace
 	}
 	return &StackTrace{
-		frames: stackTrace.frames[:n],
-		raw:    stackTrace.raw, // Note: raw string is not limited
+		frames:      stackTrace.frames[:n],
+		raw:         stackTrace.raw, // Note: raw string is not limited
+		ancestors:   stackTrace.ancestors,
+		goroutineID: stackTrace.goroutineID,
+		labels:      stackTrace.labels,
 	}
 }
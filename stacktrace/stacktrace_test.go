@@ -137,7 +137,7 @@ func TestStackTraceWithConfig(t *testing.T) {
 	}
 }
 
-func TestFunctionNameRegexp(t *testing.T) {
+func TestNormalizeFunction(t *testing.T) {
 	testCases := []struct {
 		input    string
 		expected string
@@ -145,18 +145,12 @@ func TestFunctionNameRegexp(t *testing.T) {
 		{"github.com/user/project/package.Function", "package.Function"},
 		{"main.main", "main.main"},
 		{"runtime.goexit", "runtime.goexit"},
+		{"github.com/user/project/", "github.com/user/project/"},
 	}
 
 	for _, tc := range testCases {
-		match := functionNameRegexp.FindStringSubmatch(tc.input)
-		var result string
-		if len(match) == 2 {
-			result = match[1]
-		} else {
-			result = tc.input
-		}
-		if result != tc.expected {
-			t.Errorf("functionNameRegexp.FindStringSubmatch(%q) = %q, want %q", tc.input, result, tc.expected)
+		if result := NormalizeFunction(tc.input); result != tc.expected {
+			t.Errorf("NormalizeFunction(%q) = %q, want %q", tc.input, result, tc.expected)
 		}
 	}
 }
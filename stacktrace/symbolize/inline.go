@@ -0,0 +1,184 @@
+package symbolize
+
+import (
+	"debug/dwarf"
+	"sort"
+	"sync"
+)
+
+// inlineTable resolves the functions inlined at a program counter from the DWARF
+// inlined subroutine entries of a binary.
+type inlineTable struct {
+	data        *dwarf.Data
+	subprograms []subprogram // Sorted by low address.
+
+	mu    sync.Mutex // Guards the caches below.
+	names map[dwarf.Offset]string
+	files map[dwarf.Offset][]*dwarf.LineFile
+}
+
+// subprogram is an address range of an out-of-line function.
+type subprogram struct {
+	low, high uint64
+	offset    dwarf.Offset // Offset of the subprogram entry.
+	unit      *dwarf.Entry // Compilation unit of the subprogram.
+}
+
+// inlinedCall is a function inlined at a program counter.
+type inlinedCall struct {
+	function string
+	callFile string // File of the call site in the enclosing function.
+	callLine int
+}
+
+// newInlineTable indexes the subprograms of the DWARF data.
+func newInlineTable(data *dwarf.Data) (*inlineTable, error) {
+	table := &inlineTable{
+		data:  data,
+		names: make(map[dwarf.Offset]string),
+		files: make(map[dwarf.Offset][]*dwarf.LineFile),
+	}
+	var unit *dwarf.Entry
+	reader := data.Reader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			unit = entry
+			continue
+		case dwarf.TagSubprogram:
+			ranges, err := data.Ranges(entry)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range ranges {
+				table.subprograms = append(table.subprograms, subprogram{low: r[0], high: r[1], offset: entry.Offset, unit: unit})
+			}
+		}
+		if entry.Children {
+			reader.SkipChildren()
+		}
+	}
+	sort.Slice(table.subprograms, func(i, j int) bool {
+		return table.subprograms[i].low < table.subprograms[j].low
+	})
+	return table, nil
+}
+
+// lookup returns the name of the out-of-line function containing pc and the functions
+// inlined at pc, outermost first. It reports false if no function contains pc.
+func (table *inlineTable) lookup(pc uint64) (string, []inlinedCall, bool) {
+	i := sort.Search(len(table.subprograms), func(i int) bool {
+		return table.subprograms[i].high > pc
+	})
+	if i == len(table.subprograms) || table.subprograms[i].low > pc {
+		return "", nil, false
+	}
+	subprogram := table.subprograms[i]
+
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	reader := table.data.Reader()
+	reader.Seek(subprogram.offset)
+	entry, err := reader.Next()
+	if err != nil || entry == nil {
+		return "", nil, false
+	}
+	function := table.name(entry)
+
+	var calls []inlinedCall
+	for entry.Children {
+		entry = table.child(reader, pc)
+		if entry == nil {
+			break
+		}
+		if entry.Tag == dwarf.TagInlinedSubroutine {
+			call := inlinedCall{function: table.name(entry)}
+			if index, ok := entry.Val(dwarf.AttrCallFile).(int64); ok {
+				if files := table.unitFiles(subprogram.unit); index >= 0 && index < int64(len(files)) && files[index] != nil {
+					call.callFile = files[index].Name
+				}
+			}
+			if line, ok := entry.Val(dwarf.AttrCallLine).(int64); ok {
+				call.callLine = int(line)
+			}
+			calls = append(calls, call)
+		}
+	}
+	return function, calls, true
+}
+
+// child returns the inlined subroutine or lexical block containing pc among the children
+// of the entry last read by the reader, leaving the reader on its first child.
+func (table *inlineTable) child(reader *dwarf.Reader, pc uint64) *dwarf.Entry {
+	for {
+		entry, err := reader.Next()
+		if err != nil || entry == nil || entry.Tag == 0 {
+			return nil
+		}
+		if entry.Tag == dwarf.TagInlinedSubroutine || entry.Tag == dwarf.TagLexDwarfBlock {
+			ranges, err := table.data.Ranges(entry)
+			if err == nil && containsPC(ranges, pc) {
+				return entry
+			}
+		}
+		if entry.Children {
+			reader.SkipChildren()
+		}
+	}
+}
+
+// name returns the name of a subprogram or inlined subroutine entry, following its
+// abstract origin.
+func (table *inlineTable) name(entry *dwarf.Entry) string {
+	if name, ok := entry.Val(dwarf.AttrName).(string); ok {
+		return name
+	}
+	origin, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+	if !ok {
+		return ""
+	}
+	if name, ok := table.names[origin]; ok {
+		return name
+	}
+	reader := table.data.Reader()
+	reader.Seek(origin)
+	var name string
+	if originEntry, err := reader.Next(); err == nil && originEntry != nil {
+		name, _ = originEntry.Val(dwarf.AttrName).(string)
+	}
+	table.names[origin] = name
+	return name
+}
+
+// unitFiles returns the file table of a compilation unit.
+func (table *inlineTable) unitFiles(unit *dwarf.Entry) []*dwarf.LineFile {
+	if unit == nil {
+		return nil
+	}
+	if files, ok := table.files[unit.Offset]; ok {
+		return files
+	}
+	var files []*dwarf.LineFile
+	if reader, err := table.data.LineReader(unit); err == nil && reader != nil {
+		files = reader.Files()
+	}
+	table.files[unit.Offset] = files
+	return files
+}
+
+// containsPC reports whether one of the address ranges contains pc.
+func containsPC(ranges [][2]uint64, pc uint64) bool {
+	for _, r := range ranges {
+		if r[0] <= pc && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+// Package symbolize resolves raw program counters against a Go ELF binary offline.
+// It lets stripped, address-only stack traces from production be symbolized on a
+// workstation with the binary that produced them.
+package symbolize
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+var (
+	// ErrNoLineTable is returned when the binary has no Go line table.
+	ErrNoLineTable = errors.New("symbolize: binary has no .gopclntab section")
+)
+
+// Symbolizer resolves program counters using the Go line table of an ELF binary.
+//
+// The line table maps a program counter to the file and line of the innermost inlined
+// call, but only knows the function the call was inlined into. The inlined functions
+// are resolved from the DWARF debug information; for binaries built without it, as with
+// -ldflags=-w or -s, an inlined call is attributed to the function it was inlined into,
+// which Inlining reports.
+type Symbolizer struct {
+	table   *gosym.Table
+	inlines *inlineTable // Nil if the binary has no DWARF debug information.
+	// Offset is subtracted from every program counter before resolution. Set it to the
+	// load address of position-independent executables.
+	Offset uintptr
+}
+
+// Open reads the Go line table of the ELF binary at path.
+// The line table is kept by the linker even when symbols and DWARF are stripped.
+func Open(path string) (*Symbolizer, error) {
+	file, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return New(file)
+}
+
+// New reads the Go line table of an opened ELF binary.
+func New(file *elf.File) (*Symbolizer, error) {
+	pclntab := file.Section(".gopclntab")
+	if pclntab == nil {
+		return nil, ErrNoLineTable
+	}
+	pclntabData, err := pclntab.Data()
+	if err != nil {
+		return nil, fmt.Errorf("symbolize: reading .gopclntab: %w", err)
+	}
+
+	var textStart uint64
+	if text := file.Section(".text"); text != nil {
+		textStart = text.Addr
+	}
+	var symtabData []byte
+	if symtab := file.Section(".gosymtab"); symtab != nil {
+		if symtabData, err = symtab.Data(); err != nil {
+			return nil, fmt.Errorf("symbolize: reading .gosymtab: %w", err)
+		}
+	}
+
+	table, err := gosym.NewTable(symtabData, gosym.NewLineTable(pclntabData, textStart))
+	if err != nil {
+		return nil, fmt.Errorf("symbolize: parsing line table: %w", err)
+	}
+	symbolizer := &Symbolizer{table: table}
+	if data, err := file.DWARF(); err == nil {
+		symbolizer.inlines, _ = newInlineTable(data)
+	}
+	return symbolizer, nil
+}
+
+// Inlining reports whether the binary has the debug information to resolve inlined
+// functions. Without it, the frames of inlined calls are attributed to the function
+// they were inlined into.
+func (symbolizer *Symbolizer) Inlining() bool {
+	return symbolizer.inlines != nil
+}
+
+// Frame resolves the program counter of a call, such as stacktrace.Frame.PC or
+// runtime.Frame.PC, to a frame with a normalized function name, as in stacktrace.StackTrace
+// frames. Return addresses, as returned by runtime.Callers, must be resolved with Frames.
+// If the program counter is in an inlined call, the frame is the one of the innermost
+// inlined function, as runtime.CallersFrames resolves the program counters of
+// runtime.Callers. It reports false if the program counter is unknown, in which case the
+// frame only holds the program counter.
+func (symbolizer *Symbolizer) Frame(pc uintptr) (stacktrace.Frame, bool) {
+	frames, ok := symbolizer.InlinedFrames(pc)
+	return frames[0], ok
+}
+
+// InlinedFrames resolves the program counter of a call or of a faulting instruction, as
+// Frame, to the frames of the functions inlined at it,
+// innermost first, followed by the frame of the function they were inlined into. Use it
+// for program counters which were not captured by runtime.Callers, such as a faulting
+// instruction, as runtime.Callers already reports a program counter per inlined call.
+// It reports false if the program counter is unknown, in which case the only frame holds
+// the program counter.
+func (symbolizer *Symbolizer) InlinedFrames(pc uintptr) (stacktrace.Frames, bool) {
+	address := uint64(pc - symbolizer.Offset)
+	file, line, function := symbolizer.table.PCToLine(address)
+	if function == nil {
+		return stacktrace.Frames{{PC: pc}}, false
+	}
+	name := function.Name
+	var calls []inlinedCall
+	if symbolizer.inlines != nil {
+		if outer, inlined, ok := symbolizer.inlines.lookup(address); ok && outer != "" {
+			name, calls = outer, inlined
+		}
+	}
+
+	frames := make(stacktrace.Frames, 0, len(calls)+1)
+	for i := len(calls) - 1; i >= 0; i-- {
+		frames = append(frames, stacktrace.Frame{
+			Function: stacktrace.NormalizeFunction(calls[i].function),
+			File:     file,
+			Line:     line,
+			PC:       pc,
+		})
+		file, line = calls[i].callFile, calls[i].callLine
+	}
+	return append(frames, stacktrace.Frame{
+		Function: stacktrace.NormalizeFunction(name),
+		File:     file,
+		Line:     line,
+		PC:       pc,
+	}), true
+}
+
+// Frames resolves the return addresses returned by runtime.Callers, innermost first, to
+// frames, as runtime.CallersFrames does. The frames hold the program counters of the calls,
+// as in stacktrace.Frame. Unknown program counters are kept as frames holding only the
+// program counter of the call.
+func (symbolizer *Symbolizer) Frames(pcs []uintptr) stacktrace.Frames {
+	frames := make(stacktrace.Frames, 0, len(pcs))
+	for _, pc := range pcs {
+		// Every program counter is the one following the call, including the faulting
+		// instruction of the frame interrupted by a signal, to which the runtime adds 1.
+		frame, _ := symbolizer.Frame(pc - 1)
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// Symbolize fills in the function, file and line of frames that only hold a program
+// counter, such as frames decoded from an address-only binary encoding.
+func (symbolizer *Symbolizer) Symbolize(frames stacktrace.Frames) stacktrace.Frames {
+	symbolized := make(stacktrace.Frames, len(frames))
+	for i, frame := range frames {
+		if frame.Function == "" && frame.PC != 0 {
+			frame, _ = symbolizer.Frame(frame.PC)
+		}
+		symbolized[i] = frame
+	}
+	return symbolized
+}
@@ -0,0 +1,223 @@
+package symbolize
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+func openExecutable(t *testing.T) *Symbolizer {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("test binary is not an ELF binary")
+	}
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("os.Executable returned error: %v", err)
+	}
+	file, err := elf.Open(path)
+	if err != nil {
+		t.Fatalf("elf.Open returned error: %v", err)
+	}
+	defer file.Close()
+	if file.Type == elf.ET_DYN {
+		t.Skip("test binary is position independent")
+	}
+	symbolizer, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	return symbolizer
+}
+
+func TestSymbolizerFrames(t *testing.T) {
+	symbolizer := openExecutable(t)
+
+	pcs := make([]uintptr, 3)
+	pcs = pcs[:runtime.Callers(1, pcs)] // +1 to skip runtime.Callers, inlined without DWARF
+	expected := callersFrames(pcs)
+
+	frames := symbolizer.Frames(pcs)
+	assertFrames(t, frames, expected)
+	if frames[0].Function != "symbolize.TestSymbolizerFrames" {
+		t.Errorf("first frame function = %q, want %q", frames[0].Function, "symbolize.TestSymbolizerFrames")
+	}
+}
+
+// callersFrames resolves return addresses with runtime.CallersFrames.
+func callersFrames(pcs []uintptr) stacktrace.Frames {
+	var frames stacktrace.Frames
+	resolved := runtime.CallersFrames(pcs)
+	for range pcs {
+		frame, _ := resolved.Next()
+		frames = append(frames, stacktrace.Frame{
+			Function: stacktrace.NormalizeFunction(frame.Function),
+			File:     frame.File,
+			Line:     frame.Line,
+			PC:       frame.PC,
+		})
+	}
+	return frames
+}
+
+// assertFrames reports the frames differing from the expected ones, program counters included.
+func assertFrames(t *testing.T, frames, expected stacktrace.Frames) {
+	t.Helper()
+	if len(frames) != len(expected) {
+		t.Fatalf("resolved %d frames, want %d", len(frames), len(expected))
+	}
+	for i := range expected {
+		if frames[i] != expected[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, frames[i], expected[i])
+		}
+	}
+}
+
+//go:noinline
+func dereference(p *int) int {
+	return *p
+}
+
+func TestSymbolizerFramesAfterPanic(t *testing.T) {
+	symbolizer := openExecutable(t)
+
+	var pcs []uintptr
+	func() {
+		defer func() {
+			recover()
+			pcs = make([]uintptr, 16)
+			pcs = pcs[:runtime.Callers(1, pcs)]
+		}()
+		dereference(nil)
+	}()
+
+	expected := callersFrames(pcs)
+	frames := symbolizer.Frames(pcs)
+	for i, frame := range expected[:len(expected)-1] {
+		if frame.Function == "runtime.sigpanic" {
+			if expected[i+1].Function != "symbolize.dereference" {
+				t.Fatalf("runtime frame after runtime.sigpanic is %q, want symbolize.dereference", expected[i+1].Function)
+			}
+			if frames[i+1] != expected[i+1] {
+				t.Errorf("frame of the faulting instruction = %+v, want %+v", frames[i+1], expected[i+1])
+			}
+			return
+		}
+	}
+	t.Errorf("runtime frames %v have no runtime.sigpanic frame", expected)
+}
+
+func TestSymbolizerSymbolize(t *testing.T) {
+	symbolizer := openExecutable(t)
+
+	expected := stacktrace.Callers(0, 1)
+	data, err := stacktrace.Frames{{PC: expected[0].PC}, {PC: 1}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addressOnly stacktrace.Frames
+	if err := addressOnly.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := symbolizer.Symbolize(addressOnly)
+	if !frames[0].Equal(expected[0]) {
+		t.Errorf("symbolized frame = %+v, want %+v", frames[0], expected[0])
+	}
+	if frames[1] != (stacktrace.Frame{PC: 1}) {
+		t.Errorf("unknown PC symbolized as %+v", frames[1])
+	}
+	if _, ok := symbolizer.Frame(1); ok {
+		t.Error("Symbolizer.Frame reported an unknown PC as resolved")
+	}
+}
+
+func TestOpenErrors(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Open returned no error for a missing file")
+	}
+
+	notELF := filepath.Join(t.TempDir(), "not-elf")
+	if err := os.WriteFile(notELF, []byte("not an ELF binary"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(notELF); err == nil {
+		t.Error("Open returned no error for a file that is not an ELF binary")
+	}
+
+	if _, err := New(&elf.File{}); !errors.Is(err, ErrNoLineTable) {
+		t.Errorf("New returned %v, want ErrNoLineTable", err)
+	}
+}
+
+func TestSymbolizerInlinedFrames(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test program is not an ELF binary")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	path := filepath.Join(t.TempDir(), "inlined")
+	build := exec.Command(goTool, "build", "-buildmode=exe", "-o", path, "./testdata/inlined")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build returned error: %v\n%s", err, output)
+	}
+	output, err := exec.Command(path).Output()
+	if err != nil {
+		t.Fatalf("running the test program returned error: %v", err)
+	}
+
+	var pcs []uintptr
+	var expected stacktrace.Frames
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		var pc uintptr
+		var frame stacktrace.Frame
+		if _, err := fmt.Sscanf(line, "%v %v %s %s %d", &pc, &frame.PC, &frame.Function, &frame.File, &frame.Line); err != nil {
+			t.Fatalf("parsing %q returned error: %v", line, err)
+		}
+		frame.Function = stacktrace.NormalizeFunction(frame.Function)
+		pcs = append(pcs, pc)
+		expected = append(expected, frame)
+	}
+	functions := []string{"main.leaf", "main.middle", "main.main"}
+	if len(expected) != len(functions) {
+		t.Fatalf("test program printed %d frames, want %d", len(expected), len(functions))
+	}
+	for i, function := range functions {
+		if expected[i].Function != function {
+			t.Fatalf("runtime frame %d is %q, want %q", i, expected[i].Function, function)
+		}
+	}
+
+	symbolizer, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	if !symbolizer.Inlining() {
+		t.Fatal("Symbolizer.Inlining() = false for a binary with DWARF debug information")
+	}
+	if _, _, function := symbolizer.table.PCToLine(uint64(expected[0].PC)); function.Name != "main.main" {
+		t.Skipf("main.leaf was not inlined into main.main but into %s", function.Name)
+	}
+
+	assertFrames(t, symbolizer.Frames(pcs), expected)
+
+	inlined, ok := symbolizer.InlinedFrames(expected[0].PC)
+	if !ok || len(inlined) != len(expected) {
+		t.Fatalf("Symbolizer.InlinedFrames() = %v, %v, want the %d frames of the inlined calls", inlined, ok, len(expected))
+	}
+	for i := range expected {
+		if inlined[i].Function != expected[i].Function || inlined[i].File != expected[i].File || inlined[i].Line != expected[i].Line {
+			t.Errorf("Symbolizer.InlinedFrames()[%d] = %+v, want %+v", i, inlined[i], expected[i])
+		}
+	}
+}
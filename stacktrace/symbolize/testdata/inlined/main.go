@@ -0,0 +1,34 @@
+// Command inlined prints the return addresses of an inlined call chain returned by
+// runtime.Callers, each followed by the frame runtime.CallersFrames resolves it to.
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// callers returns the program counters of its callers. It is not inlined, so that its
+// callers are inlined into main.
+//
+//go:noinline
+func callers() []uintptr {
+	pcs := make([]uintptr, 3)
+	return pcs[:runtime.Callers(2, pcs)]
+}
+
+func leaf() []uintptr {
+	return callers()
+}
+
+func middle() []uintptr {
+	return leaf()
+}
+
+func main() {
+	pcs := middle()
+	frames := runtime.CallersFrames(pcs)
+	for _, pc := range pcs {
+		frame, _ := frames.Next()
+		fmt.Printf("%#x %#x %s %s %d\n", pc, frame.PC, frame.Function, frame.File, frame.Line)
+	}
+}
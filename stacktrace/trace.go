@@ -0,0 +1,177 @@
+package stacktrace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// TraceEnv is the environment variable listing the packages traced by Trace.
+	TraceEnv = "GOKIT_TRACE"
+	// traceMaxDepth is the maximum stack depth measured for indentation.
+	traceMaxDepth = 256
+)
+
+// TraceConfig holds the configuration of Trace.
+type TraceConfig struct {
+	// Patterns selects the traced packages by import path. A pattern is "all", a path.Match
+	// pattern such as "github.com/user/project/*", or a path ending in "/..." that matches
+	// the package and its subpackages. Nothing is traced if empty.
+	Patterns []string
+	// Logger receives the trace records if set, at Level.
+	Logger *slog.Logger
+	// Level is the level of the trace records sent to Logger.
+	Level slog.Level
+	// Writer receives the trace lines if Logger is nil.
+	Writer io.Writer
+}
+
+// DefaultTraceConfig provides default configuration values, used by the package-level Trace.
+// The patterns are read from the comma-separated TraceEnv environment variable, e.g.
+// GOKIT_TRACE=github.com/user/project/...
+var DefaultTraceConfig = TraceConfig{
+	Patterns: ParseTracePatterns(os.Getenv(TraceEnv)),
+	Level:    slog.LevelDebug,
+	Writer:   os.Stderr,
+}
+
+var (
+	// traceMu serializes the trace lines written to TraceConfig.Writer.
+	traceMu sync.Mutex
+	// traceBaseDepth is the smallest stack depth traced so far, used as indentation origin.
+	traceBaseDepth atomic.Int64
+	// noTrace is returned by Trace when the caller is not traced.
+	noTrace = func() {}
+)
+
+// ParseTracePatterns splits a comma-separated list of package patterns.
+func ParseTracePatterns(value string) []string {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// Trace logs the entry of the calling function and returns a function logging its exit
+// with the elapsed time. Lines are indented by stack depth. It is used as:
+//
+//	defer stacktrace.Trace()()
+//
+// Only packages selected by DefaultTraceConfig.Patterns are traced; for other callers
+// Trace returns a no-op function.
+func Trace() func() {
+	return DefaultTraceConfig.trace(1) // +1 to skip Trace
+}
+
+// Trace traces the calling function as the package-level Trace, with the configuration.
+// A nil configuration uses DefaultTraceConfig.
+func (config *TraceConfig) Trace() func() {
+	if config == nil {
+		config = &DefaultTraceConfig
+	}
+	return config.trace(1) // +1 to skip Trace
+}
+
+// trace traces the function skip frames above its caller.
+func (config *TraceConfig) trace(skip int) func() {
+	if len(config.Patterns) == 0 {
+		return noTrace
+	}
+	raw := callers(skip+1, 1) // +1 to skip trace
+	if len(raw) == 0 || !config.traced(packagePath(raw[0].Function)) {
+		return noTrace
+	}
+	frame := raw.filter()
+	if len(frame) == 0 {
+		return noTrace
+	}
+
+	uIntPtr := make([]uintptr, traceMaxDepth)
+	depth := int64(runtime.Callers(skip+2, uIntPtr)) // +2 to skip runtime.Callers and trace
+	for base := traceBaseDepth.Load(); base == 0 || depth < base; base = traceBaseDepth.Load() {
+		if traceBaseDepth.CompareAndSwap(base, depth) {
+			break
+		}
+	}
+	indent := int(max(depth-traceBaseDepth.Load(), 0))
+	goroutineID := currentGoroutineID()
+
+	config.emit(goroutineID, indent, frame[0], "enter", 0)
+	start := time.Now()
+	return func() {
+		config.emit(goroutineID, indent, frame[0], "exit", time.Since(start))
+	}
+}
+
+// traced reports whether the package matches one of the patterns.
+func (config *TraceConfig) traced(packagePath string) bool {
+	for _, pattern := range config.Patterns {
+		switch {
+		case pattern == "all":
+			return true
+		case strings.HasSuffix(pattern, "/..."):
+			prefix := strings.TrimSuffix(pattern, "/...")
+			if packagePath == prefix || strings.HasPrefix(packagePath, prefix+"/") {
+				return true
+			}
+		default:
+			if matched, _ := path.Match(pattern, packagePath); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emit writes a single trace record to the logger or the writer.
+func (config *TraceConfig) emit(goroutineID int64, indent int, frame Frame, event string, elapsed time.Duration) {
+	if config.Logger != nil {
+		attrs := []slog.Attr{
+			slog.String("function", frame.Function),
+			slog.String("file", frame.File),
+			slog.Int("line", frame.Line),
+			slog.Int("depth", indent),
+			slog.Int64("goroutine", goroutineID),
+		}
+		if event == "exit" {
+			attrs = append(attrs, slog.Duration("elapsed", elapsed))
+		}
+		config.Logger.LogAttrs(context.Background(), config.Level, strings.Repeat("  ", indent)+event+" "+frame.Function, attrs...)
+		return
+	}
+	if config.Writer == nil {
+		return
+	}
+	var line string
+	if event == "enter" {
+		line = fmt.Sprintf("[g%d] %s-> %s %s:%d\n", goroutineID, strings.Repeat("  ", indent), frame.Function, frame.File, frame.Line)
+	} else {
+		line = fmt.Sprintf("[g%d] %s<- %s %s\n", goroutineID, strings.Repeat("  ", indent), frame.Function, elapsed)
+	}
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	_, _ = io.WriteString(config.Writer, line)
+}
+
+// packagePath returns the import path of the package of a fully qualified function name,
+// such as "github.com/user/project/pkg" for "github.com/user/project/pkg.(*T).Method".
+// Dots in the last path element are escaped as "%2e" by the runtime.
+func packagePath(function string) string {
+	slash := strings.LastIndexByte(function, '/') + 1
+	if dot := strings.IndexByte(function[slash:], '.'); dot >= 0 {
+		function = function[:slash+dot]
+	}
+	return strings.ReplaceAll(function, "%2e", ".")
+}
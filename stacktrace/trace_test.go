@@ -0,0 +1,135 @@
+package stacktrace
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func tracedOuter(config *TraceConfig) {
+	defer config.Trace()()
+	tracedInner(config)
+}
+
+func tracedInner(config *TraceConfig) {
+	defer config.Trace()()
+}
+
+func tracedDefault() {
+	defer Trace()()
+}
+
+func TestTrace(t *testing.T) {
+	var buf bytes.Buffer
+	tracedOuter(&TraceConfig{Patterns: []string{"github.com/turtak/go-kit/..."}, Writer: &buf})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Trace wrote %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	expected := []string{"-> stacktrace.tracedOuter ", "-> stacktrace.tracedInner ", "<- stacktrace.tracedInner ", "<- stacktrace.tracedOuter "}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "[g") || !strings.Contains(line, expected[i]) {
+			t.Errorf("line %d = %q, want %q", i, line, expected[i])
+		}
+	}
+	indent := func(line string) int {
+		line = line[strings.IndexByte(line, ']')+2:]
+		return len(line) - len(strings.TrimLeft(line, " "))
+	}
+	if indent(lines[1]) <= indent(lines[0]) || indent(lines[2]) != indent(lines[1]) || indent(lines[3]) != indent(lines[0]) {
+		t.Errorf("lines are not indented by stack depth:\n%s", buf.String())
+	}
+	if !strings.Contains(lines[0], "trace_test.go:") {
+		t.Errorf("entry line does not contain the caller location: %q", lines[0])
+	}
+}
+
+func TestTraceSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tracedInner(&TraceConfig{Patterns: []string{"all"}, Logger: logger, Level: slog.LevelDebug})
+
+	output := buf.String()
+	if strings.Count(output, "level=DEBUG") != 2 || !strings.Contains(output, "function=stacktrace.tracedInner") {
+		t.Errorf("unexpected slog output:\n%s", output)
+	}
+	if !strings.Contains(output, "exit stacktrace.tracedInner") || !strings.Contains(output, "elapsed=") {
+		t.Errorf("exit record is missing the elapsed time:\n%s", output)
+	}
+}
+
+func TestTraceDisabled(t *testing.T) {
+	var buf bytes.Buffer
+	tracedOuter(&TraceConfig{Patterns: []string{"github.com/other/*"}, Writer: &buf})
+	tracedOuter(&TraceConfig{Writer: &buf})
+	if buf.Len() != 0 {
+		t.Errorf("Trace wrote output for an untraced package: %q", buf.String())
+	}
+}
+
+func TestTraceDefaultConfig(t *testing.T) {
+	var buf bytes.Buffer
+	previous := DefaultTraceConfig
+	DefaultTraceConfig = TraceConfig{Patterns: []string{"github.com/turtak/go-kit/..."}, Writer: &buf}
+	defer func() { DefaultTraceConfig = previous }()
+
+	tracedDefault()
+	var config *TraceConfig
+	tracedInner(config)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{"-> stacktrace.tracedDefault ", "<- stacktrace.tracedDefault ", "-> stacktrace.tracedInner ", "<- stacktrace.tracedInner "}
+	if len(lines) != len(expected) {
+		t.Fatalf("Trace wrote %d lines, want %d:\n%s", len(lines), len(expected), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.Contains(line, expected[i]) {
+			t.Errorf("line %d = %q, want %q", i, line, expected[i])
+		}
+	}
+}
+
+func TestTraceConfigTraced(t *testing.T) {
+	config := TraceConfig{Patterns: ParseTracePatterns(" github.com/user/app/..., example.com/*/db ,")}
+	testCases := []struct {
+		packagePath string
+		expected    bool
+	}{
+		{"github.com/user/app", true},
+		{"github.com/user/app/internal/cache", true},
+		{"github.com/user/application", false},
+		{"example.com/billing/db", true},
+		{"example.com/billing/db/sql", false},
+		{"main", false},
+	}
+
+	for _, tc := range testCases {
+		if traced := config.traced(tc.packagePath); traced != tc.expected {
+			t.Errorf("traced(%q) = %v, want %v", tc.packagePath, traced, tc.expected)
+		}
+	}
+}
+
+func TestPackagePath(t *testing.T) {
+	testCases := map[string]string{
+		"github.com/user/app/pkg.(*T).Method": "github.com/user/app/pkg",
+		"main.main":                           "main",
+		"gopkg.in/yaml%2ev3.Unmarshal":        "gopkg.in/yaml.v3",
+		"runtime":                             "runtime",
+	}
+	for function, expected := range testCases {
+		if packagePath := packagePath(function); packagePath != expected {
+			t.Errorf("packagePath(%q) = %q, want %q", function, packagePath, expected)
+		}
+	}
+}
+
+func BenchmarkTraceDisabled(b *testing.B) {
+	config := &TraceConfig{Patterns: []string{"github.com/other/..."}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		config.Trace()()
+	}
+}
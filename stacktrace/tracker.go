@@ -0,0 +1,104 @@
+package stacktrace
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// trackerDepth is the maximum number of frames recorded per acquisition.
+	trackerDepth = 32
+)
+
+// Tracker records the stack at which resources such as files, connections, locks or
+// pooled buffers are acquired, until they are released. Resources still held when they
+// should not be, for example at the end of a test, are reported by Outstanding grouped
+// by acquisition stack. It is safe for concurrent use.
+type Tracker[K comparable] struct {
+	mu   sync.Mutex
+	live map[K][]uintptr // Acquisition program counters by resource.
+}
+
+// Outstanding is a group of live resources acquired at the same stack.
+type Outstanding[K comparable] struct {
+	Frames Frames // Filtered frames of the acquisition stack, starting at the caller of Acquire.
+	Keys   []K    // Resources acquired at the stack, in no particular order.
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker[K comparable]() *Tracker[K] {
+	return &Tracker[K]{live: make(map[K][]uintptr)}
+}
+
+// Acquire records the calling stack as the acquisition site of the resource.
+// Acquiring a live resource again replaces its acquisition site.
+func (tracker *Tracker[K]) Acquire(key K) {
+	uIntPtr := make([]uintptr, trackerDepth)
+	n := runtime.Callers(2, uIntPtr) // +2 to skip runtime.Callers and Acquire
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.live[key] = uIntPtr[:n:n]
+}
+
+// Release forgets the resource and reports whether it was live.
+func (tracker *Tracker[K]) Release(key K) bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	_, ok := tracker.live[key]
+	delete(tracker.live, key)
+	return ok
+}
+
+// Len returns the number of live resources.
+func (tracker *Tracker[K]) Len() int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return len(tracker.live)
+}
+
+// Outstanding returns the live resources grouped by acquisition stack, largest group first.
+func (tracker *Tracker[K]) Outstanding() []Outstanding[K] {
+	tracker.mu.Lock()
+	groups := make(map[string]*Outstanding[K])
+	pcs := make(map[string][]uintptr)
+	for key, uIntPtr := range tracker.live {
+		id := fmt.Sprint(uIntPtr)
+		group, ok := groups[id]
+		if !ok {
+			group = &Outstanding[K]{}
+			groups[id] = group
+			pcs[id] = uIntPtr
+		}
+		group.Keys = append(group.Keys, key)
+	}
+	tracker.mu.Unlock()
+
+	outstanding := make([]Outstanding[K], 0, len(groups))
+	for id, group := range groups {
+		group.Frames = framesOf(pcs[id]).filter()
+		outstanding = append(outstanding, *group)
+	}
+	sort.Slice(outstanding, func(i, j int) bool {
+		if len(outstanding[i].Keys) != len(outstanding[j].Keys) {
+			return len(outstanding[i].Keys) > len(outstanding[j].Keys)
+		}
+		return outstanding[i].Frames.String() < outstanding[j].Frames.String()
+	})
+	return outstanding
+}
+
+// String returns a report of the live resources grouped by acquisition stack.
+func (tracker *Tracker[K]) String() string {
+	var builder strings.Builder
+	for i, group := range tracker.Outstanding() {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		fmt.Fprintf(&builder, "%d outstanding acquired at:\n%s\n", len(group.Keys), group.Frames.CollapsedString(DefaultCollapseRepeats))
+	}
+	return builder.String()
+}
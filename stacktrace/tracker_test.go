@@ -0,0 +1,82 @@
+package stacktrace
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func acquireConnection(tracker *Tracker[int], id int) {
+	tracker.Acquire(id)
+}
+
+func acquireBuffer(tracker *Tracker[int], id int) {
+	tracker.Acquire(id)
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker[int]()
+	for id := 0; id < 3; id++ {
+		acquireConnection(tracker, id)
+	}
+	acquireBuffer(tracker, 10)
+
+	if tracker.Len() != 4 {
+		t.Errorf("Tracker.Len() = %d, want 4", tracker.Len())
+	}
+	if !tracker.Release(1) {
+		t.Error("Tracker.Release returned false for a live resource")
+	}
+	if tracker.Release(1) {
+		t.Error("Tracker.Release returned true for a released resource")
+	}
+
+	outstanding := tracker.Outstanding()
+	if len(outstanding) != 2 {
+		t.Fatalf("Tracker.Outstanding() returned %d groups, want 2", len(outstanding))
+	}
+	if len(outstanding[0].Keys) != 2 || outstanding[0].Frames[0].Function != "stacktrace.acquireConnection" {
+		t.Errorf("first group = %d keys acquired at %v", len(outstanding[0].Keys), outstanding[0].Frames)
+	}
+	if len(outstanding[1].Keys) != 1 || outstanding[1].Keys[0] != 10 || outstanding[1].Frames[0].Function != "stacktrace.acquireBuffer" {
+		t.Errorf("second group = %v acquired at %v", outstanding[1].Keys, outstanding[1].Frames)
+	}
+
+	report := tracker.String()
+	if !strings.HasPrefix(report, "2 outstanding acquired at:\n") || !strings.Contains(report, "1 outstanding acquired at:\n") {
+		t.Errorf("Tracker.String() = %q", report)
+	}
+
+	for _, id := range []int{0, 2, 10} {
+		tracker.Release(id)
+	}
+	if len(tracker.Outstanding()) != 0 || tracker.String() != "" {
+		t.Error("tracker has outstanding resources after releasing all of them")
+	}
+}
+
+func TestTrackerConcurrent(t *testing.T) {
+	tracker := NewTracker[int]()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			tracker.Acquire(id)
+			_ = tracker.Outstanding()
+			tracker.Release(id)
+		}(i)
+	}
+	wg.Wait()
+	if tracker.Len() != 0 {
+		t.Errorf("Tracker.Len() = %d, want 0", tracker.Len())
+	}
+}
+
+func BenchmarkTrackerAcquireRelease(b *testing.B) {
+	tracker := NewTracker[int]()
+	for i := 0; i < b.N; i++ {
+		tracker.Acquire(i)
+		tracker.Release(i)
+	}
+}
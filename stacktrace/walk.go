@@ -0,0 +1,44 @@
+package stacktrace
+
+import (
+	"iter"
+	"runtime"
+)
+
+const (
+	// maxWalkDepth is the maximum number of program counters read by Walk.
+	maxWalkDepth = 256
+)
+
+// Walk returns a sequence lazily yielding the frames of the goroutine ranging over it,
+// innermost first, skipping skip frames above the function ranging over it. Frames are
+// filtered and normalized as in StackTrace.Frames, but neither a Frames slice nor the raw
+// stack trace is built: the program counters are kept on the stack and resolved one at
+// a time, so stopping early, such as at the first frame of a package, avoids resolving
+// the rest. Walking does not allocate, except for frames of inlined calls, whose function
+// is described by the runtime in a small allocated value. At most maxWalkDepth program
+// counters are read.
+func Walk(skip int) iter.Seq[Frame] {
+	return func(yield func(Frame) bool) {
+		var pcs [maxWalkDepth]uintptr
+		n := runtime.Callers(skip+2, pcs[:]) // +2 to skip runtime.Callers and the sequence
+		for _, pc := range pcs[:n] {
+			// Program counters point after the call instruction, including the faulting
+			// instruction of the frame interrupted by a signal, to which the runtime adds 1.
+			pc--
+			function := runtime.FuncForPC(pc)
+			if function == nil {
+				continue
+			}
+			file, line := function.FileLine(pc)
+			frame := Frame{Function: function.Name(), File: file, Line: line, PC: pc}
+			if !frame.valid() {
+				continue
+			}
+			frame.Function = NormalizeFunction(frame.Function)
+			if !yield(frame) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package stacktrace
+
+import (
+	"strings"
+	"testing"
+)
+
+func walkHelper() Frames {
+	var frames Frames
+	for frame := range Walk(1) {
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func TestWalk(t *testing.T) {
+	var first Frame
+	for frame := range Walk(0) {
+		first = frame
+		break
+	}
+	if first.Function != "stacktrace.TestWalk" || !strings.HasSuffix(first.File, "walk_test.go") {
+		t.Errorf("first frame of Walk(0) = %+v, want TestWalk", first)
+	}
+
+	frames := walkHelper()
+	if len(frames) < 2 || frames[0].Function != "stacktrace.TestWalk" || frames[1].Function != "testing.tRunner" {
+		t.Errorf("Walk(1) = %v, want TestWalk then testing.tRunner", frames)
+	}
+	callers := Callers(0, len(frames))
+	for i := range callers {
+		if i >= len(frames) || frames[i].Function != callers[i].Function || frames[i].File != callers[i].File {
+			t.Errorf("Walk(1) = %v, want the frames of Callers:\n%v", frames, callers)
+			break
+		}
+	}
+
+	for range Walk(1000) {
+		t.Error("Walk(1000) yielded a frame")
+	}
+}
+
+func TestWalkPanic(t *testing.T) {
+	var walked, captured Frames
+	func() {
+		defer func() {
+			recover()
+			for frame := range Walk(0) {
+				walked = append(walked, frame)
+			}
+			captured = Callers(0, len(walked))
+		}()
+		var pointer *Frame
+		_ = pointer.Line
+	}()
+
+	if len(walked) != len(captured) {
+		t.Fatalf("Walk yielded %d frames during a panic, want %d:\n%v\n%v", len(walked), len(captured), walked, captured)
+	}
+	// The first frames differ only by the line of the calls to Walk and Callers.
+	for i := 1; i < len(walked); i++ {
+		if walked[i] != captured[i] {
+			t.Errorf("Walk frame %d = %+v, want %+v", i, walked[i], captured[i])
+		}
+	}
+}
+
+func TestWalkAllocations(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		for frame := range Walk(0) {
+			if frame.Function == "testing.tRunner" {
+				break
+			}
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Walk allocated %.0f times per run, want 0", allocs)
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for frame := range Walk(0) {
+			if frame.Function == "testing.tRunner" {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package stacktrace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// WatchConfig holds the configuration of Watch.
+type WatchConfig struct {
+	// MaxReports is the maximum number of reports per call, 0 for no limit. While the
+	// function is stuck, a report is emitted every threshold.
+	MaxReports int
+	// Report receives the reports. It is called from a separate goroutine. If nil, the
+	// reports go to DefaultWatchConfig.Report.
+	Report func(report WatchReport)
+}
+
+// DefaultWatchConfig provides default configuration values, used by the package-level Watch.
+// It reports to the standard error.
+var DefaultWatchConfig = WatchConfig{
+	MaxReports: 3,
+	Report:     func(report WatchReport) { writeWatchReport(os.Stderr, report) },
+}
+
+// WatchReport describes a function running longer than the threshold of Watch.
+type WatchReport struct {
+	// Caller is the frame of the caller of Watch.
+	Caller Frame
+	// Elapsed is the time the function had been running when the report was emitted.
+	Elapsed time.Duration
+	// Count is the number of the report for the call, starting at 1.
+	Count int
+	// Goroutine is the state and the current stack of the goroutine running the function.
+	Goroutine Goroutine
+}
+
+// String returns the human-readable rendering of the report.
+func (report WatchReport) String() string {
+	return fmt.Sprintf("slow call from %s (%s:%d) running for %s\ngoroutine %d [%s] at:\n%s",
+		report.Caller.Function, report.Caller.File, report.Caller.Line, report.Elapsed,
+		report.Goroutine.ID, report.Goroutine.State, report.Goroutine.Frames.CollapsedString(DefaultCollapseRepeats))
+}
+
+// writeWatchReport writes the report followed by a blank line.
+func writeWatchReport(w io.Writer, report WatchReport) {
+	fmt.Fprintf(w, "%s\n\n", report)
+}
+
+// Watch calls fn on the calling goroutine and returns its error. If fn has not returned
+// within the threshold, the current stack of the goroutine, taken from a dump of all the
+// goroutines, is reported to DefaultWatchConfig.Report, and again every threshold while
+// fn runs, up to DefaultWatchConfig.MaxReports times. No report is emitted once Watch
+// returns or ctx is done. The reports show where the call is stuck rather than only that
+// it was slow. A threshold that is not positive disables the reports.
+func Watch(ctx context.Context, threshold time.Duration, fn func(ctx context.Context) error) error {
+	return DefaultWatchConfig.watch(ctx, threshold, fn)
+}
+
+// Watch calls fn as the package-level Watch, reporting with the configuration.
+// A nil configuration uses DefaultWatchConfig.
+func (config *WatchConfig) Watch(ctx context.Context, threshold time.Duration, fn func(ctx context.Context) error) error {
+	if config == nil {
+		config = &DefaultWatchConfig
+	}
+	return config.watch(ctx, threshold, fn)
+}
+
+// watch implements Watch for the caller of its caller.
+func (config *WatchConfig) watch(ctx context.Context, threshold time.Duration, fn func(ctx context.Context) error) error {
+	if threshold <= 0 {
+		return fn(ctx)
+	}
+	caller := Caller(2) // +2 to skip watch and Watch
+	maxReports, report := config.MaxReports, config.Report
+	if report == nil {
+		report = DefaultWatchConfig.Report
+	}
+	if report == nil {
+		report = func(report WatchReport) { writeWatchReport(os.Stderr, report) }
+	}
+	goroutineID := currentGoroutineID()
+	start := time.Now()
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(threshold)
+		defer ticker.Stop()
+		for count := 1; maxReports == 0 || count <= maxReports; {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			goroutine, ok := findGoroutine(goroutineID)
+			if !ok {
+				continue
+			}
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			default:
+			}
+			report(WatchReport{Caller: caller, Elapsed: time.Since(start), Count: count, Goroutine: goroutine})
+			count++
+		}
+	}()
+
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+	return fn(ctx)
+}
+
+// findGoroutine returns the goroutine with the ID from a dump of all the goroutines.
+func findGoroutine(id int64) (Goroutine, bool) {
+	for _, goroutine := range Goroutines() {
+		if goroutine.ID == id {
+			return goroutine, true
+		}
+	}
+	return Goroutine{}, false
+}
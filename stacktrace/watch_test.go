@@ -0,0 +1,142 @@
+package stacktrace
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func stuckInWatch(release <-chan struct{}) error {
+	<-release
+	return errors.New("released")
+}
+
+func TestWatch(t *testing.T) {
+	var mu sync.Mutex
+	var reports []WatchReport
+	release := make(chan struct{})
+	config := &WatchConfig{MaxReports: 2, Report: func(report WatchReport) {
+		mu.Lock()
+		defer mu.Unlock()
+		reports = append(reports, report)
+		if len(reports) == 2 {
+			close(release)
+		}
+	}}
+
+	err := config.Watch(context.Background(), 10*time.Millisecond, func(ctx context.Context) error {
+		return stuckInWatch(release)
+	})
+	if err == nil || err.Error() != "released" {
+		t.Errorf("Watch() = %v, want the error of fn", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 2 {
+		t.Fatalf("%d reports, want 2", len(reports))
+	}
+	report := reports[0]
+	if report.Caller.Function != "stacktrace.TestWatch" || report.Count != 1 || report.Elapsed < 10*time.Millisecond {
+		t.Errorf("report = %+v", report)
+	}
+	if report.Goroutine.ID != currentGoroutineID() || !strings.HasPrefix(report.Goroutine.State, "chan receive") {
+		t.Errorf("report goroutine = %d [%s], want the watched goroutine", report.Goroutine.ID, report.Goroutine.State)
+	}
+	if !strings.Contains(report.Goroutine.Frames.String(), "stacktrace.stuckInWatch") {
+		t.Errorf("report frames do not show where fn is stuck:\n%s", report.Goroutine.Frames)
+	}
+	if !strings.Contains(report.String(), "slow call from stacktrace.TestWatch") {
+		t.Errorf("report String() = %q", report.String())
+	}
+	if reports[1].Count != 2 {
+		t.Errorf("second report Count = %d, want 2", reports[1].Count)
+	}
+}
+
+func TestWatchFast(t *testing.T) {
+	config := &WatchConfig{Report: func(report WatchReport) {
+		t.Errorf("unexpected report for a fast call:\n%s", report)
+	}}
+
+	if err := config.Watch(context.Background(), time.Hour, func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("WatchConfig.Watch() = %v, want nil", err)
+	}
+}
+
+func TestWatchDefaultConfig(t *testing.T) {
+	reports := make(chan WatchReport, 2)
+	previous := DefaultWatchConfig
+	DefaultWatchConfig = WatchConfig{MaxReports: 1, Report: func(report WatchReport) { reports <- report }}
+	defer func() { DefaultWatchConfig = previous }()
+
+	var config *WatchConfig
+	for _, watch := range []func(context.Context, time.Duration, func(context.Context) error) error{Watch, config.Watch} {
+		release := make(chan struct{})
+		go func() {
+			<-reports
+			close(release)
+		}()
+		if err := watch(context.Background(), time.Millisecond, func(ctx context.Context) error { return stuckInWatch(release) }); err == nil {
+			t.Error("Watch() = nil, want the error of fn")
+		}
+	}
+}
+
+func TestWatchWithoutThreshold(t *testing.T) {
+	config := &WatchConfig{Report: func(report WatchReport) {
+		t.Errorf("unexpected report without a threshold:\n%s", report)
+	}}
+
+	for _, threshold := range []time.Duration{0, -time.Second} {
+		called := false
+		err := config.Watch(context.Background(), threshold, func(ctx context.Context) error {
+			called = true
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		})
+		if err != nil || !called {
+			t.Errorf("WatchConfig.Watch() with threshold %s = %v, called %v, want fn called", threshold, err, called)
+		}
+	}
+}
+
+func TestWatchCanceled(t *testing.T) {
+	config := &WatchConfig{Report: func(report WatchReport) {
+		t.Errorf("unexpected report after the context was canceled:\n%s", report)
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := config.Watch(ctx, time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WatchConfig.Watch() = %v, want context.Canceled", err)
+	}
+}
+
+func TestWatchNilReport(t *testing.T) {
+	reports := make(chan WatchReport, 1)
+	previous := DefaultWatchConfig.Report
+	DefaultWatchConfig.Report = func(report WatchReport) { reports <- report }
+	defer func() { DefaultWatchConfig.Report = previous }()
+
+	release := make(chan struct{})
+	go func() {
+		select {
+		case <-reports:
+		case <-time.After(5 * time.Second):
+			t.Error("no report sent to DefaultWatchConfig.Report")
+		}
+		close(release)
+	}()
+	config := &WatchConfig{MaxReports: 1}
+	if err := config.Watch(context.Background(), 5*time.Millisecond, func(ctx context.Context) error { return stuckInWatch(release) }); err == nil {
+		t.Error("WatchConfig.Watch() = nil, want the error of fn")
+	}
+}
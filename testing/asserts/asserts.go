@@ -8,7 +8,9 @@ import (
 	"math"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -27,6 +29,9 @@ var (
 		BufferSize: 2048,
 		SkipFrames: 2,
 	}
+
+	// expandedTests holds the tests whose failure stack traces render every recursive frame.
+	expandedTests sync.Map
 )
 
 // failTest reports a test failure and prints the stack trace.
@@ -37,10 +42,44 @@ func failTest(t *testing.T, msg string) {
 		return
 	}
 	stackTrace := stacktrace.NewStackTrace(stacktraceConfig)
-	fmt.Printf("--- Stack trace ---\n%s\n-------------------\n", stackTrace.Frames().String())
+	fmt.Printf("--- Stack trace%s ---\n%s\n-------------------\n", goroutineInfo(stackTrace), renderFrames(t, stackTrace.Frames()))
 	t.Error(msg)
 }
 
+// ExpandRecursion disables collapsing of recursive frames in the failure stack traces of
+// the test or subtest t, until it ends.
+func ExpandRecursion(t *testing.T) {
+	expandedTests.Store(t, true)
+	t.Cleanup(func() { expandedTests.Delete(t) })
+}
+
+// renderFrames renders the frames of a failure stack trace of t, collapsing recursion unless
+// ExpandRecursion was called for t.
+func renderFrames(t *testing.T, frames stacktrace.Frames) string {
+	if _, ok := expandedTests.Load(t); ok {
+		return frames.CollapsedString(0)
+	}
+	return frames.String()
+}
+
+// goroutineInfo describes the goroutine and pprof labels of a stack trace for the failure header.
+func goroutineInfo(stackTrace *stacktrace.StackTrace) string {
+	if stackTrace.GoroutineID() == 0 {
+		return ""
+	}
+	info := fmt.Sprintf(" (goroutine %d", stackTrace.GoroutineID())
+	labels := stackTrace.Labels()
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		info += fmt.Sprintf(" %s=%s", key, labels[key])
+	}
+	return info + ")"
+}
+
 // isNil checks whether the given value is nil.
 func isNil(value any) bool {
 	if value == nil {
@@ -594,6 +633,14 @@ func ElementsMatch(t *testing.T, listA, listB any) {
 	}
 }
 
+// NoOutstanding asserts that a tracker has no live resources.
+// It fails the test with the acquisition stacks of the resources that were not released.
+func NoOutstanding[K comparable](t *testing.T, tracker *stacktrace.Tracker[K]) {
+	if tracker.Len() > 0 {
+		failTest(t, fmt.Sprintf("%d outstanding resources:\n%s", tracker.Len(), tracker.String()))
+	}
+}
+
 // haveSameElements is a helper function for ElementsMatch.
 func haveSameElements(listA, listB any) bool {
 	valA := reflect.ValueOf(listA)
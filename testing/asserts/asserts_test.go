@@ -1,10 +1,15 @@
 package asserts
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"runtime/pprof"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/turtak/go-kit/stacktrace"
 )
 
 func mockTestingEnable() {
@@ -32,6 +37,39 @@ func TestIsNil(t *testing.T) {
 	}
 }
 
+func TestGoroutineInfo(t *testing.T) {
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("tenant", "acme", "request", "r-1"))
+	info := goroutineInfo(stacktrace.NewStackTraceContext(ctx, nil))
+	if !strings.HasPrefix(info, " (goroutine ") || !strings.HasSuffix(info, " request=r-1 tenant=acme)") {
+		t.Errorf("goroutineInfo returned %q", info)
+	}
+	if info := goroutineInfo(stacktrace.NewStackTrace(&stacktrace.Config{})); info != "" {
+		t.Errorf("goroutineInfo returned %q for a stack trace without raw text", info)
+	}
+}
+
+func TestRenderFrames(t *testing.T) {
+	frames := stacktrace.Frames{
+		{Function: "p.walk", File: "/src/walk.go", Line: 10},
+		{Function: "p.walk", File: "/src/walk.go", Line: 12},
+		{Function: "p.walk", File: "/src/walk.go", Line: 12},
+		{Function: "p.walk", File: "/src/walk.go", Line: 12},
+	}
+	if rendered := renderFrames(t, frames); !strings.Contains(rendered, "... 3 more frames of p.walk ...") {
+		t.Errorf("renderFrames did not collapse recursion: %q", rendered)
+	}
+
+	t.Run("ExpandRecursion", func(t *testing.T) {
+		ExpandRecursion(t)
+		if rendered := renderFrames(t, frames); rendered != frames.CollapsedString(0) {
+			t.Errorf("renderFrames with ExpandRecursion = %q, want %q", rendered, frames.CollapsedString(0))
+		}
+	})
+	if rendered := renderFrames(t, frames); !strings.Contains(rendered, "more frames of p.walk") {
+		t.Errorf("ExpandRecursion of a subtest expanded the frames of its parent: %q", rendered)
+	}
+}
+
 func TestCompareNumeric(t *testing.T) {
 	t.Run("Test Equal", func(t *testing.T) {
 		num, noErr := compareNumeric(5, 5)
@@ -796,6 +834,23 @@ func TestPanicsWithValue(t *testing.T) {
 	})
 }
 
+func TestNoOutstanding(t *testing.T) {
+	t.Run("NoOutstanding", func(t *testing.T) {
+		tracker := stacktrace.NewTracker[string]()
+		tracker.Acquire("conn")
+		tracker.Release("conn")
+		NoOutstanding(t, tracker)
+	})
+
+	t.Run("Outstanding", func(t *testing.T) {
+		mockTestingEnable()
+		tracker := stacktrace.NewTracker[string]()
+		tracker.Acquire("conn")
+		NoOutstanding(t, tracker)
+		mockTestMessageCheck(t, "1 outstanding resources:\n1 outstanding acquired at:\n")
+	})
+}
+
 func TestInDelta(t *testing.T) {
 	t.Run("InDelta", func(t *testing.T) {
 		InDelta(t, 5.0, 5.1, 0.2)
@@ -856,3 +911,71 @@ func TestElementsMatch(t *testing.T) {
 		mockTestMessageCheck(t, "element lists are not equal: expected: [1 2 3] actual: [4 5]")
 	})
 }
+
+func captureSnapshotStack() *stacktrace.StackTrace {
+	var stackTrace *stacktrace.StackTrace
+	func() {
+		stackTrace = stacktrace.NewStackTrace(&stacktrace.Config{BufferSize: 4096, SkipFrames: 0})
+	}()
+	return stackTrace
+}
+
+func TestStackSnapshot(t *testing.T) {
+	t.Run("Match", func(t *testing.T) {
+		StackSnapshot(t, captureSnapshotStack(), "stack_snapshot", &stacktrace.NormalizeConfig{MaskLines: true})
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		mockTestingEnable()
+		StackSnapshot(t, captureSnapshotStack(), "stack_snapshot", nil)
+		mockTestMessageCheck(t, "stack trace does not match snapshot testdata/stack_snapshot.golden")
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		mockTestingEnable()
+		StackSnapshot(t, captureSnapshotStack(), "missing", nil)
+		mockTestMessageCheck(t, "run with GOKIT_UPDATE_SNAPSHOTS=1 to create it")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		previousDir := snapshotDir
+		snapshotDir = t.TempDir()
+		defer func() { snapshotDir = previousDir }()
+
+		stackTrace := captureSnapshotStack()
+		t.Setenv(UpdateSnapshotsEnv, "1")
+		StackSnapshot(t, stackTrace, "updated", nil)
+		t.Setenv(UpdateSnapshotsEnv, "")
+		mockTestingEnable()
+		StackSnapshot(t, stackTrace, "updated", nil)
+		if mockTestMessage != "" {
+			t.Errorf("snapshot written with GOKIT_UPDATE_SNAPSHOTS=1 does not match: %s", mockTestMessage)
+		}
+	})
+
+	t.Run("UpdateFlag", func(t *testing.T) {
+		previousDir := snapshotDir
+		snapshotDir = t.TempDir()
+		defer func() { snapshotDir = previousDir }()
+
+		if updateSnapshots() {
+			t.Skip("snapshots are being updated")
+		}
+		if flag.Lookup(updateSnapshotsFlag) == nil {
+			flag.Bool(updateSnapshotsFlag, false, "rewrite the golden files")
+		}
+		stackTrace := captureSnapshotStack()
+		if err := flag.Set(updateSnapshotsFlag, "true"); err != nil {
+			t.Fatal(err)
+		}
+		StackSnapshot(t, stackTrace, "flag", nil)
+		if err := flag.Set(updateSnapshotsFlag, "false"); err != nil {
+			t.Fatal(err)
+		}
+		mockTestingEnable()
+		StackSnapshot(t, stackTrace, "flag", nil)
+		if mockTestMessage != "" {
+			t.Errorf("snapshot written with -update does not match: %s", mockTestMessage)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package asserts
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/turtak/go-kit/stacktrace"
+)
+
+const (
+	// UpdateSnapshotsEnv is the environment variable which, set to a true value such as 1,
+	// makes StackSnapshot rewrite the golden files instead of comparing them.
+	UpdateSnapshotsEnv = "GOKIT_UPDATE_SNAPSHOTS"
+
+	// updateSnapshotsFlag is the name of the command line flag honored like UpdateSnapshotsEnv.
+	updateSnapshotsFlag = "update"
+)
+
+var (
+	// snapshotDir is the directory of the golden files, relative to the package directory.
+	snapshotDir = "testdata"
+)
+
+// StackSnapshot asserts that the normalized frames of a stack trace match the golden file
+// testdata/<name>.golden. The frames are normalized with the configuration, whose root and
+// module default to those of the go.mod enclosing the package when both are empty. A nil
+// configuration keeps line numbers and drops the frames outside the module.
+// Run the tests with GOKIT_UPDATE_SNAPSHOTS=1, or with -update if the test binary defines
+// that flag, to write the golden files. The package does not register -update itself,
+// since a flag defined twice panics and many test packages define their own.
+func StackSnapshot(t *testing.T, stackTrace *stacktrace.StackTrace, name string, config *stacktrace.NormalizeConfig) {
+	t.Helper()
+	normalizeConfig := stacktrace.NormalizeConfig{}
+	if config != nil {
+		normalizeConfig = *config
+	}
+	if normalizeConfig.Root == "" && normalizeConfig.Module == "" {
+		normalizeConfig.Root, normalizeConfig.Module = findModule()
+	}
+	actual := stackTrace.Frames().Normalize(&normalizeConfig).String() + "\n"
+
+	path := filepath.Join(snapshotDir, name+".golden")
+	if updateSnapshots() {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("creating snapshot directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatalf("writing snapshot: %v", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		failTest(t, fmt.Sprintf("reading snapshot %s: %v (run with %s=1 to create it)", path, err, UpdateSnapshotsEnv))
+		return
+	}
+	if string(expected) != actual {
+		failTest(t, fmt.Sprintf("stack trace does not match snapshot %s (run with %s=1 to rewrite it):\nexpected:\n%sactual:\n%s",
+			path, UpdateSnapshotsEnv, expected, actual))
+	}
+}
+
+// updateSnapshots reports whether the golden files are rewritten instead of compared.
+func updateSnapshots() bool {
+	if update, err := strconv.ParseBool(os.Getenv(UpdateSnapshotsEnv)); err == nil && update {
+		return true
+	}
+	if updateFlag := flag.Lookup(updateSnapshotsFlag); updateFlag != nil {
+		update, err := strconv.ParseBool(updateFlag.Value.String())
+		return err == nil && update
+	}
+	return false
+}
+
+// findModule returns the directory and the path of the module enclosing the working directory,
+// or empty strings if there is none.
+func findModule() (string, string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", ""
+	}
+	for {
+		if module, ok := readModulePath(filepath.Join(dir, "go.mod")); ok {
+			return dir, module
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the module path declared by a go.mod file.
+func readModulePath(path string) (string, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), true
+		}
+	}
+	return "", true
+}